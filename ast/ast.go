@@ -2,13 +2,16 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey/token"
+	"strconv"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Span() token.Span
 }
 
 type Statement interface {
@@ -33,6 +36,14 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) == 0 {
+		return token.Span{}
+	}
+
+	return join(spanOf(p.Statements[0]), spanOf(p.Statements[len(p.Statements)-1]))
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -46,17 +57,28 @@ func (p *Program) String() string {
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
-	Value Expression
+	// Pattern, if set instead of Name, destructures the value into the
+	// names it binds.
+	Pattern Pattern
+	Value   Expression
+	// Doc is the text of the /// comment directly above the statement,
+	// or "" if there is none.
+	Doc string
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Span() token.Span     { return join(ls.Token.Span, spanOf(ls.Value)) }
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -68,6 +90,14 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Names returns the identifiers the statement binds.
+func (ls *LetStatement) Names() []*Identifier {
+	if ls.Pattern != nil {
+		return Bindings(ls.Pattern)
+	}
+	return []*Identifier{ls.Name}
+}
+
 type Identifier struct {
 	Token token.Token
 	Value string
@@ -76,6 +106,7 @@ type Identifier struct {
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Span() token.Span     { return i.Token.Span }
 
 type ReturnStatement struct {
 	Token       token.Token
@@ -84,6 +115,9 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Span() token.Span {
+	return join(rs.Token.Span, spanOf(rs.ReturnValue))
+}
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -98,6 +132,162 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// ThrowStatement raises Value as an error, which aborts evaluation up to
+// the nearest enclosing try expression.
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Span() token.Span {
+	return join(ts.Token.Span, spanOf(ts.Value))
+}
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// AssignStatement updates an existing variable, or an element of an array
+// or hash when Target is an index expression. Operator is "=" or a compound
+// operator such as "+=", which combines the current value with Value.
+type AssignStatement struct {
+	Token    token.Token // the assignment operator
+	Target   Expression  // an *Identifier or *IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Span() token.Span {
+	return join(spanOf(as.Target), spanOf(as.Value))
+}
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" " + as.Operator + " ")
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// WhileStatement runs Body as long as Condition is truthy.
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Span() token.Span {
+	if ws.Body != nil {
+		return join(ws.Token.Span, ws.Body.Span())
+	}
+	return join(ws.Token.Span, spanOf(ws.Condition))
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("while (")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(ws.Body.String())
+
+	return out.String()
+}
+
+// ForStatement runs Body once for each element of an array, character of
+// a string or key of a hash, bound to Variable.
+type ForStatement struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Span() token.Span {
+	if fs.Body != nil {
+		return join(fs.Token.Span, fs.Body.Span())
+	}
+	return join(fs.Token.Span, spanOf(fs.Iterable))
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Span() token.Span     { return bs.Token.Span }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Span() token.Span     { return cs.Token.Span }
+func (cs *ContinueStatement) String() string       { return "continue;" }
+
+// ImportStatement binds the module found at Path to Name. Without an
+// explicit name, Name is derived from the last element of the path and has
+// the span of the path.
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Name  *Identifier
+	Path  *StringLiteral
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Span() token.Span     { return join(is.Token.Span, is.Path.Span()) }
+func (is *ImportStatement) String() string {
+	return "import " + is.Name.String() + " " + strconv.Quote(is.Path.Value) + ";"
+}
+
+// ExportStatement makes the binding of Statement part of the module, so
+// programs importing the module can use it.
+type ExportStatement struct {
+	Token     token.Token // the 'export' token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Span() token.Span     { return join(es.Token.Span, es.Statement.Span()) }
+func (es *ExportStatement) String() string       { return "export " + es.Statement.String() }
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -105,6 +295,9 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Span() token.Span {
+	return join(es.Token.Span, spanOf(es.Expression))
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -115,11 +308,25 @@ func (es *ExpressionStatement) String() string {
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	// Big holds the value instead of Value when it does not fit in an
+	// int64.
+	Big *big.Int
 }
 
 func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
+func (i *IntegerLiteral) Span() token.Span     { return i.Token.Span }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) expressionNode()      {}
+func (f *FloatLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FloatLiteral) String() string       { return f.Token.Literal }
+func (f *FloatLiteral) Span() token.Span     { return f.Token.Span }
 
 type StringLiteral struct {
 	Token token.Token
@@ -129,6 +336,7 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Span() token.Span     { return sl.Token.Span }
 
 type Boolean struct {
 	Token token.Token
@@ -138,6 +346,16 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Span() token.Span     { return b.Token.Span }
+
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (n *NullLiteral) expressionNode()      {}
+func (n *NullLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *NullLiteral) String() string       { return n.Token.Literal }
+func (n *NullLiteral) Span() token.Span     { return n.Token.Span }
 
 type PrefixExpression struct {
 	Token    token.Token
@@ -147,6 +365,9 @@ type PrefixExpression struct {
 
 func (pexp *PrefixExpression) expressionNode()      {}
 func (pexp *PrefixExpression) TokenLiteral() string { return pexp.Token.Literal }
+func (pexp *PrefixExpression) Span() token.Span {
+	return join(pexp.Token.Span, spanOf(pexp.Right))
+}
 func (pexp *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -167,6 +388,9 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Span() token.Span {
+	return join(spanOf(ie.Left), spanOf(ie.Right))
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -179,6 +403,9 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// IfExpression is an if with an optional else. In an else if chain the
+// Alternative is a block, whose Token is the nested 'if', holding nothing
+// but the next IfExpression.
 type IfExpression struct {
 	Token       token.Token
 	Condition   Expression
@@ -188,6 +415,15 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Span() token.Span {
+	if ie.Alternative != nil {
+		return join(ie.Token.Span, ie.Alternative.Span())
+	}
+	if ie.Consequence != nil {
+		return join(ie.Token.Span, ie.Consequence.Span())
+	}
+	return join(ie.Token.Span, spanOf(ie.Condition))
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -204,13 +440,257 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression runs Block and, if it raises an error, binds the error to
+// Param and runs Catch. Finally runs afterwards in any case. At least one
+// of Catch and Finally is set.
+type TryExpression struct {
+	Token   token.Token // the 'try' token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Span() token.Span {
+	switch {
+	case te.Finally != nil:
+		return join(te.Token.Span, te.Finally.Span())
+	case te.Catch != nil:
+		return join(te.Token.Span, te.Catch.Span())
+	case te.Block != nil:
+		return join(te.Token.Span, te.Block.Span())
+	}
+	return te.Token.Span
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.Param.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// MatchExpression evaluates to the body of the first arm whose pattern
+// matches Subject and whose guard, if any, is truthy.
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Token
+}
+
+// MatchArm is one `pattern if guard => body` case of a match expression.
+// Guard is nil for an arm without one.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expression
+	Body    Expression
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Span() token.Span     { return join(me.Token.Span, me.Rbrace.Span) }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, arm := range me.Arms {
+		s := arm.Pattern.String()
+		if arm.Guard != nil {
+			s += " if " + arm.Guard.String()
+		}
+		arms = append(arms, s+" => "+arm.Body.String())
+	}
+
+	out.WriteString("match (")
+	out.WriteString(me.Subject.String())
+	out.WriteString(") { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// Pattern is the shape of the values a match arm accepts. Matching a value
+// binds the identifiers in the pattern to the parts of the value they
+// stand for.
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// As a pattern, an identifier matches any value and binds it, except for
+// the wildcard "_", which binds nothing.
+func (i *Identifier) patternNode() {}
+
+// LiteralPattern matches the values equal to a literal integer, float,
+// string, boolean or null. Value is that literal, or a negated number.
+type LiteralPattern struct {
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Value.TokenLiteral() }
+func (lp *LiteralPattern) Span() token.Span     { return spanOf(lp.Value) }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+
+// ArrayPattern matches arrays whose elements match Elements. Without Rest
+// the array must have as many elements as the pattern; otherwise it may
+// have more, and Rest is bound to an array of them.
+type ArrayPattern struct {
+	Token    token.Token // the '[' token
+	Elements []Pattern
+	Rest     *Identifier
+	Rbracket token.Token
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Span() token.Span     { return join(ap.Token.Span, ap.Rbracket.Span) }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern matches hashes that have all of Keys, which are literals,
+// with values matching the pattern at the same index of Values. Other
+// keys of the hash are ignored.
+type HashPattern struct {
+	Token  token.Token // the '{' token
+	Keys   []Expression
+	Values []Pattern
+	Rbrace token.Token
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Span() token.Span     { return join(hp.Token.Span, hp.Rbrace.Span) }
+func (hp *HashPattern) String() string {
+	entries := []string{}
+	for i, key := range hp.Keys {
+		entries = append(entries, key.String()+": "+hp.Values[i].String())
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// DefaultPattern is an element of an array or hash pattern that binds
+// Name like an identifier, or binds the value of Default if the element
+// is missing or null.
+type DefaultPattern struct {
+	Token   token.Token // the '=' token
+	Name    *Identifier
+	Default Expression
+}
+
+func (dp *DefaultPattern) patternNode()         {}
+func (dp *DefaultPattern) TokenLiteral() string { return dp.Token.Literal }
+func (dp *DefaultPattern) Span() token.Span     { return join(dp.Name.Span(), spanOf(dp.Default)) }
+func (dp *DefaultPattern) String() string {
+	return dp.Name.String() + " = " + dp.Default.String()
+}
+
+// Bindings returns the identifiers a pattern binds when it matches, in the
+// order they appear in it.
+func Bindings(p Pattern) []*Identifier {
+	var names []*Identifier
+
+	switch p := p.(type) {
+	case *Identifier:
+		if p.Value != "_" {
+			names = append(names, p)
+		}
+	case *ArrayPattern:
+		for _, el := range p.Elements {
+			names = append(names, Bindings(el)...)
+		}
+		if p.Rest != nil {
+			names = append(names, Bindings(p.Rest)...)
+		}
+	case *HashPattern:
+		for _, value := range p.Values {
+			names = append(names, Bindings(value)...)
+		}
+	case *DefaultPattern:
+		names = append(names, Bindings(p.Name)...)
+	}
+
+	return names
+}
+
+// Required returns the number of leading elements up to the last one
+// without a default, which is how many values elements needs at least.
+func Required(elements []Pattern) int {
+	for i := len(elements) - 1; i >= 0; i-- {
+		if _, ok := elements[i].(*DefaultPattern); !ok {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Arity returns the least and the most number of arguments a function
+// with parameters and rest takes. max is -1 if rest collects any number
+// beyond min.
+func Arity(parameters []Pattern, rest *Identifier) (min, max int) {
+	if rest != nil {
+		return Required(parameters), -1
+	}
+	return Required(parameters), len(parameters)
+}
+
+// Defaults returns the elements of a pattern that have a default, in the
+// order they appear in it.
+func Defaults(p Pattern) []*DefaultPattern {
+	var defaults []*DefaultPattern
+
+	switch p := p.(type) {
+	case *ArrayPattern:
+		for _, el := range p.Elements {
+			defaults = append(defaults, Defaults(el)...)
+		}
+	case *HashPattern:
+		for _, value := range p.Values {
+			defaults = append(defaults, Defaults(value)...)
+		}
+	case *DefaultPattern:
+		defaults = append(defaults, p)
+	}
+
+	return defaults
+}
+
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // the '{' token
 	Statements []Statement
+	Rbrace     token.Token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Span() token.Span     { return join(bs.Token.Span, bs.Rbrace.Span) }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -222,13 +702,25 @@ func (bs *BlockStatement) String() string {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token token.Token
+	Name  string // set when the literal is bound with let
+	// Parameters are identifiers, names with a default, or array and
+	// hash patterns that destructure the argument at their position.
+	Parameters []Pattern
+	// Rest, if set, is bound to an array of the arguments after those
+	// for Parameters.
+	Rest *Identifier
+	Body *BlockStatement
 }
 
 func (fn *FunctionLiteral) expressionNode()      {}
 func (fn *FunctionLiteral) TokenLiteral() string { return fn.Token.Literal }
+func (fn *FunctionLiteral) Span() token.Span {
+	if fn.Body == nil {
+		return fn.Token.Span
+	}
+	return join(fn.Token.Span, fn.Body.Span())
+}
 func (fn *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -236,6 +728,9 @@ func (fn *FunctionLiteral) String() string {
 	for _, p := range fn.Parameters {
 		params = append(params, p.String())
 	}
+	if fn.Rest != nil {
+		params = append(params, "..."+fn.Rest.String())
+	}
 
 	out.WriteString(fn.TokenLiteral())
 	out.WriteString("(")
@@ -247,13 +742,17 @@ func (fn *FunctionLiteral) String() string {
 }
 
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // the '(' token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Span() token.Span {
+	return join(join(spanOf(ce.Function), ce.Token.Span), ce.Rparen.Span)
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -270,13 +769,71 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// SpreadExpression is an argument of a call, ...Value, that passes the
+// elements of the array Value as separate arguments.
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Span() token.Span     { return join(se.Token.Span, spanOf(se.Value)) }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// ExpansionError takes the place of a macro call that could not be
+// expanded, such as one with the wrong number of arguments. Evaluating it
+// raises an error of Kind with Message. Limit is set if the expansion
+// exceeded a limit of the evaluation, which makes the error uncatchable.
+type ExpansionError struct {
+	Call    *CallExpression
+	Kind    string
+	Message string
+	Limit   string
+}
+
+func (ee *ExpansionError) expressionNode()      {}
+func (ee *ExpansionError) TokenLiteral() string { return ee.Call.TokenLiteral() }
+func (ee *ExpansionError) Span() token.Span     { return ee.Call.Span() }
+func (ee *ExpansionError) String() string       { return ee.Call.String() }
+
+// InterpolatedString is a string with embedded expressions, such as
+// "hello ${name}". Parts alternates between the text, as StringLiterals,
+// and the expressions; empty text is left out.
+type InterpolatedString struct {
+	Token token.Token // the STRING_HEAD token
+	Parts []Expression
+	Tail  token.Token // the STRING_TAIL token
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Span() token.Span     { return join(is.Token.Span, is.Tail.Span) }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if str, ok := part.(*StringLiteral); ok {
+			out.WriteString(str.String())
+			continue
+		}
+		out.WriteString("${")
+		out.WriteString(part.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
-	Token    token.Token
+	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Token
 }
 
 func (a *ArrayLiteral) expressionNode()      {}
 func (a *ArrayLiteral) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayLiteral) Span() token.Span     { return join(a.Token.Span, a.Rbracket.Span) }
 func (a *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -293,13 +850,17 @@ func (a *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the '[' token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token
 }
 
 func (a *IndexExpression) expressionNode()      {}
 func (a *IndexExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *IndexExpression) Span() token.Span {
+	return join(join(spanOf(a.Left), a.Token.Span), a.Rbracket.Span)
+}
 func (a *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -313,12 +874,14 @@ func (a *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Token
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Span() token.Span     { return join(hl.Token.Span, hl.Rbrace.Span) }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -335,13 +898,24 @@ func (hl *HashLiteral) String() string {
 }
 
 type MacroLiteral struct {
-	Token      token.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token token.Token
+	// Parameters are Identifiers and, after them, DefaultPatterns; macros
+	// do not destructure their arguments.
+	Parameters []Pattern
+	// Rest, if set, is bound to an array of the arguments after those
+	// for Parameters.
+	Rest *Identifier
+	Body *BlockStatement
 }
 
 func (m *MacroLiteral) expressionNode()      {}
 func (m *MacroLiteral) TokenLiteral() string { return m.Token.Literal }
+func (m *MacroLiteral) Span() token.Span {
+	if m.Body == nil {
+		return m.Token.Span
+	}
+	return join(m.Token.Span, m.Body.Span())
+}
 func (m *MacroLiteral) String() string {
 	var out bytes.Buffer
 
@@ -349,6 +923,9 @@ func (m *MacroLiteral) String() string {
 	for _, p := range m.Parameters {
 		params = append(params, p.String())
 	}
+	if m.Rest != nil {
+		params = append(params, "..."+m.Rest.String())
+	}
 
 	out.WriteString(m.TokenLiteral())
 	out.WriteString("(")
@@ -358,3 +935,23 @@ func (m *MacroLiteral) String() string {
 
 	return out.String()
 }
+
+// spanOf returns the span of n, or an invalid span if n is missing.
+func spanOf(n Node) token.Span {
+	if n == nil {
+		return token.Span{}
+	}
+	return n.Span()
+}
+
+// join returns a span from the start of start to the end of end. Missing
+// spans on either side are ignored.
+func join(start, end token.Span) token.Span {
+	if !end.IsValid() {
+		return start
+	}
+	if !start.IsValid() {
+		return end
+	}
+	return token.Span{Start: start.Start, End: end.End}
+}
@@ -95,9 +95,99 @@ func TestModify(t *testing.T) {
 			&LetStatement{Value: one()},
 			&LetStatement{Value: two()},
 		},
+		{
+			&LetStatement{
+				Pattern: &ArrayPattern{
+					Elements: []Pattern{&DefaultPattern{Name: &Identifier{Value: "a"}, Default: one()}},
+				},
+				Value: one(),
+			},
+			&LetStatement{
+				Pattern: &ArrayPattern{
+					Elements: []Pattern{&DefaultPattern{Name: &Identifier{Value: "a"}, Default: two()}},
+				},
+				Value: two(),
+			},
+		},
+		{
+			&ExportStatement{Statement: &LetStatement{Value: one()}},
+			&ExportStatement{Statement: &LetStatement{Value: two()}},
+		},
+		{
+			&ThrowStatement{Value: one()},
+			&ThrowStatement{Value: two()},
+		},
+		{
+			&TryExpression{
+				Block: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+				Param: &Identifier{Value: "e"},
+				Catch: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+				Finally: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&TryExpression{
+				Block: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+				Param: &Identifier{Value: "e"},
+				Catch: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+				Finally: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{
+			&MatchExpression{
+				Subject: one(),
+				Arms: []*MatchArm{
+					{
+						Pattern: &ArrayPattern{
+							Elements: []Pattern{
+								&LiteralPattern{Value: one()},
+								&HashPattern{
+									Keys:   []Expression{one()},
+									Values: []Pattern{&LiteralPattern{Value: one()}},
+								},
+							},
+						},
+						Guard: one(),
+						Body:  one(),
+					},
+				},
+			},
+			&MatchExpression{
+				Subject: two(),
+				Arms: []*MatchArm{
+					{
+						Pattern: &ArrayPattern{
+							Elements: []Pattern{
+								&LiteralPattern{Value: two()},
+								&HashPattern{
+									Keys:   []Expression{two()},
+									Values: []Pattern{&LiteralPattern{Value: two()}},
+								},
+							},
+						},
+						Guard: two(),
+						Body:  two(),
+					},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{&SpreadExpression{Value: one()}}},
+			&CallExpression{Function: two(), Arguments: []Expression{&SpreadExpression{Value: two()}}},
+		},
 		{
 			&FunctionLiteral{
-				Parameters: []*Identifier{},
+				Parameters: []Pattern{},
 				Body: &BlockStatement{
 					Statements: []Statement{
 						&ExpressionStatement{Expression: one()},
@@ -105,7 +195,75 @@ func TestModify(t *testing.T) {
 				},
 			},
 			&FunctionLiteral{
-				Parameters: []*Identifier{},
+				Parameters: []Pattern{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&AssignStatement{Target: &IndexExpression{Left: one(), Index: one()}, Operator: "+=", Value: one()},
+			&AssignStatement{Target: &IndexExpression{Left: two(), Index: two()}, Operator: "+=", Value: two()},
+		},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: &IfExpression{
+							Condition:   one(),
+							Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+						}},
+					},
+				},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: &IfExpression{
+							Condition:   two(),
+							Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+						}},
+					},
+				},
+			},
+		},
+		{
+			&WhileStatement{
+				Condition: one(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&WhileStatement{
+				Condition: two(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			&ForStatement{
+				Variable: &Identifier{Value: "x"},
+				Iterable: one(),
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			&ForStatement{
+				Variable: &Identifier{Value: "x"},
+				Iterable: two(),
 				Body: &BlockStatement{
 					Statements: []Statement{
 						&ExpressionStatement{Expression: two()},
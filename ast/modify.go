@@ -12,21 +12,42 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		return modifier(p)
 
 	case *FunctionLiteral:
-		fn := &FunctionLiteral{Token: node.Token}
+		fn := &FunctionLiteral{Token: node.Token, Name: node.Name, Rest: node.Rest}
+		fn.Parameters = make([]Pattern, 0, len(node.Parameters))
 		for _, param := range node.Parameters {
-			fn.Parameters = append(fn.Parameters, Modify(param, modifier).(*Identifier))
+			fn.Parameters = append(fn.Parameters, Modify(param, modifier).(Pattern))
 		}
 		fn.Body = Modify(node.Body, modifier).(*BlockStatement)
 		return modifier(fn)
+	case *MacroLiteral:
+		macro := &MacroLiteral{Token: node.Token, Rest: node.Rest}
+		macro.Parameters = make([]Pattern, 0, len(node.Parameters))
+		for _, param := range node.Parameters {
+			macro.Parameters = append(macro.Parameters, Modify(param, modifier).(Pattern))
+		}
+		macro.Body = Modify(node.Body, modifier).(*BlockStatement)
+		return modifier(macro)
+	case *SpreadExpression:
+		se := &SpreadExpression{Token: node.Token}
+		se.Value, _ = Modify(node.Value, modifier).(Expression)
+
+		return modifier(se)
+	case *InterpolatedString:
+		str := &InterpolatedString{Token: node.Token, Tail: node.Tail}
+		for _, part := range node.Parts {
+			str.Parts = append(str.Parts, Modify(part, modifier).(Expression))
+		}
+
+		return modifier(str)
 	case *ArrayLiteral:
-		arr := &ArrayLiteral{Token: node.Token}
+		arr := &ArrayLiteral{Token: node.Token, Rbracket: node.Rbracket}
 		for _, elem := range node.Elements {
 			arr.Elements = append(arr.Elements, Modify(elem, modifier).(Expression))
 		}
 
 		return modifier(arr)
 	case *CallExpression:
-		ce := &CallExpression{Token: node.Token}
+		ce := &CallExpression{Token: node.Token, Rparen: node.Rparen}
 		ce.Function = Modify(node.Function, modifier).(Expression)
 		for _, arg := range node.Arguments {
 			ce.Arguments = append(ce.Arguments, Modify(arg, modifier).(Expression))
@@ -34,7 +55,7 @@ func Modify(node Node, modifier ModifierFunc) Node {
 
 		return modifier(ce)
 	case *HashLiteral:
-		hash := &HashLiteral{Token: node.Token}
+		hash := &HashLiteral{Token: node.Token, Rbrace: node.Rbrace}
 
 		pairs := make(map[Expression]Expression)
 		for key, val := range node.Pairs {
@@ -46,7 +67,7 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		hash.Pairs = pairs
 		return modifier(hash)
 	case *IndexExpression:
-		iexpr := &IndexExpression{Token: node.Token}
+		iexpr := &IndexExpression{Token: node.Token, Rbracket: node.Rbracket}
 		iexpr.Left, _ = Modify(node.Left, modifier).(Expression)
 		iexpr.Index, _ = Modify(node.Index, modifier).(Expression)
 
@@ -66,11 +87,84 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		ifexpr := &IfExpression{Token: node.Token}
 		ifexpr.Condition, _ = Modify(node.Condition, modifier).(Expression)
 		ifexpr.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
-		ifexpr.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			ifexpr.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
 
 		return modifier(ifexpr)
+	case *TryExpression:
+		te := &TryExpression{Token: node.Token, Param: node.Param}
+		te.Block, _ = Modify(node.Block, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			te.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			te.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
+
+		return modifier(te)
+	case *MatchExpression:
+		me := &MatchExpression{Token: node.Token, Rbrace: node.Rbrace}
+		me.Subject, _ = Modify(node.Subject, modifier).(Expression)
+		for _, arm := range node.Arms {
+			modified := &MatchArm{}
+			modified.Pattern, _ = Modify(arm.Pattern, modifier).(Pattern)
+			if arm.Guard != nil {
+				modified.Guard, _ = Modify(arm.Guard, modifier).(Expression)
+			}
+			modified.Body, _ = Modify(arm.Body, modifier).(Expression)
+			me.Arms = append(me.Arms, modified)
+		}
+
+		return modifier(me)
+	case *LiteralPattern:
+		lp := &LiteralPattern{}
+		lp.Value, _ = Modify(node.Value, modifier).(Expression)
+
+		return modifier(lp)
+	case *ArrayPattern:
+		ap := &ArrayPattern{Token: node.Token, Rest: node.Rest, Rbracket: node.Rbracket}
+		for _, el := range node.Elements {
+			modified, _ := Modify(el, modifier).(Pattern)
+			ap.Elements = append(ap.Elements, modified)
+		}
+
+		return modifier(ap)
+	case *HashPattern:
+		hp := &HashPattern{Token: node.Token, Rbrace: node.Rbrace}
+		for i, key := range node.Keys {
+			modifiedKey, _ := Modify(key, modifier).(Expression)
+			modifiedValue, _ := Modify(node.Values[i], modifier).(Pattern)
+			hp.Keys = append(hp.Keys, modifiedKey)
+			hp.Values = append(hp.Values, modifiedValue)
+		}
+
+		return modifier(hp)
+	case *DefaultPattern:
+		dp := &DefaultPattern{Token: node.Token, Name: node.Name}
+		dp.Default, _ = Modify(node.Default, modifier).(Expression)
+
+		return modifier(dp)
+	case *AssignStatement:
+		as := &AssignStatement{Token: node.Token, Operator: node.Operator}
+		as.Target, _ = Modify(node.Target, modifier).(Expression)
+		as.Value, _ = Modify(node.Value, modifier).(Expression)
+
+		return modifier(as)
+	case *WhileStatement:
+		ws := &WhileStatement{Token: node.Token}
+		ws.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		ws.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+		return modifier(ws)
+	case *ForStatement:
+		fs := &ForStatement{Token: node.Token, Variable: node.Variable}
+		fs.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		fs.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+		return modifier(fs)
 	case *BlockStatement:
-		block := &BlockStatement{Token: node.Token}
+		block := &BlockStatement{Token: node.Token, Rbrace: node.Rbrace}
 		for _, stmt := range node.Statements {
 			block.Statements = append(block.Statements, Modify(stmt, modifier).(Statement))
 		}
@@ -78,21 +172,35 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		return modifier(block)
 	case *ReturnStatement:
 		rstmt := &ReturnStatement{Token: node.Token}
-		rstmt.ReturnValue = Modify(node.ReturnValue, modifier).(Expression)
+		rstmt.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
 
 		return modifier(rstmt)
+	case *ThrowStatement:
+		ts := &ThrowStatement{Token: node.Token}
+		ts.Value, _ = Modify(node.Value, modifier).(Expression)
+
+		return modifier(ts)
 	case *LetStatement:
-		ls := &LetStatement{Token: node.Token}
-		ls.Value = Modify(node.Value, modifier).(Expression)
+		ls := &LetStatement{Token: node.Token, Name: node.Name, Doc: node.Doc}
+		if node.Pattern != nil {
+			ls.Pattern, _ = Modify(node.Pattern, modifier).(Pattern)
+		}
+		ls.Value, _ = Modify(node.Value, modifier).(Expression)
 
 		return modifier(ls)
+	case *ExportStatement:
+		es := &ExportStatement{Token: node.Token}
+		es.Statement, _ = Modify(node.Statement, modifier).(*LetStatement)
+
+		return modifier(es)
 	case *ExpressionStatement:
 		estmt := &ExpressionStatement{Token: node.Token}
 		estmt.Expression, _ = Modify(node.Expression, modifier).(Expression)
 
 		return modifier(estmt)
+	case nil:
+		return nil
 	default:
-		return node
+		return modifier(node)
 	}
-
 }
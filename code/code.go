@@ -0,0 +1,322 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			break
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJumpNotTruthy
+	OpJump
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpGetFree
+	OpCurrentClosure
+	// OpUndefined raises "identifier not found" for the name stored in
+	// the constant pool. It is emitted for names the compiler cannot
+	// resolve, so the error only happens if the code actually runs.
+	OpUndefined
+
+	OpArray
+	OpHash
+	OpIndex
+	// OpInterpolate pops the values of the parts of an interpolated
+	// string and pushes the string.
+	OpInterpolate
+
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpClosure
+
+	// OpQuote pops the values of the unquote calls inside a quoted node
+	// and splices them into the node stored in the constant pool.
+	OpQuote
+
+	// OpIter pops the value a for loop iterates over and pushes an
+	// iterator over it.
+	OpIter
+	// OpIterNext pops an iterator and pushes its next value, or jumps to
+	// its operand when it is exhausted.
+	OpIterNext
+
+	// OpSetFree assigns to a free variable of the current closure.
+	OpSetFree
+	// OpCaptureLocal and OpCaptureFree push a reference to a local or free
+	// variable for OpClosure, rather than its value.
+	OpCaptureLocal
+	OpCaptureFree
+	// OpDupPair duplicates the two values on top of the stack.
+	OpDupPair
+	// OpSetIndex pops a value, an index and an array or hash, and stores
+	// the value at the index.
+	OpSetIndex
+
+	OpMod
+	OpPow
+	OpGreaterEqual
+	OpLessEqual
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+
+	// OpImport pushes the module whose path is the string constant at its
+	// operand.
+	OpImport
+
+	// OpTry installs a handler at its operand for the errors raised until
+	// the matching OpEndTry. The handler starts with the caught exception
+	// on the stack.
+	OpTry
+	OpEndTry
+	// OpThrow pops a value and raises it as an error.
+	OpThrow
+
+	// OpMatch pops a value and tests it against the pattern constant at
+	// its operand. If it matches, it pushes the values the pattern binds
+	// followed by true, otherwise only false.
+	OpMatch
+	// OpNoMatch pops the value no arm of a match expression matched and
+	// raises an error.
+	OpNoMatch
+	// OpDestructure pops a value and pushes the values the pattern
+	// constant at its operand binds in it, or raises an error if the
+	// value does not match.
+	OpDestructure
+	// OpSpread pops an array and pushes a marker that OpCallSpread
+	// replaces with its elements.
+	OpSpread
+	// OpCallSpread is like OpCall, but the arguments include spread
+	// markers, so the number of them is only known when it runs.
+	OpCallSpread
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:         {"OpAdd", []int{}},
+	OpSub:         {"OpSub", []int{}},
+	OpMul:         {"OpMul", []int{}},
+	OpDiv:         {"OpDiv", []int{}},
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+
+	OpMod:          {"OpMod", []int{}},
+	OpPow:          {"OpPow", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpImport:       {"OpImport", []int{2}},
+	OpTry:          {"OpTry", []int{2}},
+	OpEndTry:       {"OpEndTry", []int{}},
+	OpThrow:        {"OpThrow", []int{}},
+	OpMatch:        {"OpMatch", []int{2}},
+	OpNoMatch:      {"OpNoMatch", []int{}},
+	OpDestructure:  {"OpDestructure", []int{2}},
+	OpSpread:       {"OpSpread", []int{}},
+	OpCallSpread:   {"OpCallSpread", []int{1}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpUndefined:      {"OpUndefined", []int{2}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+
+	OpQuote: {"OpQuote", []int{2, 1}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2}},
+
+	OpSetFree:      {"OpSetFree", []int{1}},
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}},
+	OpDupPair:      {"OpDupPair", []int{}},
+	OpSetIndex:     {"OpSetIndex", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// CheckOperands returns an error if an operand of op does not fit in its
+// width, in which case Make would truncate it.
+func CheckOperands(op Opcode, operands ...int) error {
+	def, err := Lookup(byte(op))
+	if err != nil {
+		return err
+	}
+
+	for i, o := range operands {
+		max := 1<<(8*uint(def.OperandWidths[i])) - 1
+		if o < 0 || o > max {
+			return fmt.Errorf("operand %d of %s out of range, the limit is %d", o, def.Name, max)
+		}
+	}
+
+	return nil
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
@@ -0,0 +1,1225 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/eval"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// span is the node currently being compiled. emit records it for
+	// every instruction so the vm can report error positions.
+	span token.Span
+
+	// overflow is set to an error for the first operand that did not fit
+	// its instruction, such as the index of the 65537th constant. Compile
+	// reports it once the program is compiled.
+	overflow error
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           map[int]token.Span
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	// loops holds the loops enclosing the statement being compiled, the
+	// innermost last.
+	loops []*loop
+	// tries holds the try expressions whose handlers are installed while
+	// the statement being compiled runs, the innermost last.
+	tries []*try
+	// matches counts the match expressions enclosing the node being
+	// compiled.
+	matches int
+}
+
+// loop collects the jumps emitted for the break and continue statements of
+// a loop body until the positions they jump to are known.
+type loop struct {
+	breaks    []int
+	continues []int
+}
+
+// try is a try expression whose handler is installed. Statements leaving it
+// early remove the handler and run its finally block first.
+type try struct {
+	finally *ast.BlockStatement
+	// loops is the number of loops enclosing the try expression.
+	loops int
+	// blocks is the number of blocks of the symbol table open outside the
+	// try expression, the names of which its finally block sees.
+	blocks int
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    map[int]token.Span
+	GlobalNames  []string
+}
+
+var infixOperators = map[string]code.Opcode{
+	token.PLUS:     code.OpAdd,
+	token.MINUS:    code.OpSub,
+	token.ASTERISK: code.OpMul,
+	token.SLASH:    code.OpDiv,
+	token.EQ:       code.OpEqual,
+	token.NEQ:      code.OpNotEqual,
+	token.GT:       code.OpGreaterThan,
+	token.LT:       code.OpLessThan,
+
+	token.PERCENT:     code.OpMod,
+	token.POWER:       code.OpPow,
+	token.GT_EQ:       code.OpGreaterEqual,
+	token.LT_EQ:       code.OpLessEqual,
+	token.AMPERSAND:   code.OpBitAnd,
+	token.PIPE:        code.OpBitOr,
+	token.CARET:       code.OpBitXor,
+	token.SHIFT_LEFT:  code.OpShiftLeft,
+	token.SHIFT_RIGHT: code.OpShiftRight,
+}
+
+var prefixOperators = map[string]code.Opcode{
+	token.MINUS: code.OpMinus,
+	token.BANG:  code.OpBang,
+}
+
+func New() *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, name := range eval.BuiltinNames() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{newCompilationScope()},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState returns a compiler that continues from the symbol table and
+// constants of an earlier one, as the REPL does between lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+func newCompilationScope() CompilationScope {
+	return CompilationScope{
+		instructions: code.Instructions{},
+		positions:    make(map[int]token.Span),
+	}
+}
+
+// SymbolTable returns the global symbol table, to be handed to
+// NewWithState.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if node != nil {
+		outer := c.span
+		c.span = node.Span()
+		defer func() { c.span = outer }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		if c.symbolTable.Outer == nil {
+			c.hoist(node.Statements)
+		}
+
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+		// The value of a program is the value of its last statement, which
+		// is null for a loop, or nothing if that is a let.
+		switch {
+		case endsWithExpression(node.Statements):
+			c.replaceLastPopWithReturn()
+		case endsWithLoop(node.Statements):
+			c.emit(code.OpNull)
+			c.emit(code.OpReturnValue)
+		default:
+			c.emit(code.OpReturn)
+		}
+
+		if c.overflow != nil {
+			return c.overflow
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		if node.Pattern != nil {
+			if err := c.Compile(node.Value); err != nil {
+				return err
+			}
+			return c.compileDestructure(node.Pattern)
+		}
+
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		c.storeSymbol(symbol)
+
+	case *ast.ImportStatement:
+		path := c.addConstant(&object.String{Value: node.Path.Value})
+		c.emit(code.OpImport, path)
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.leaveTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.AssignStatement:
+		return c.compileAssign(node)
+
+	case *ast.WhileStatement:
+		start := len(c.currentInstructions())
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exit := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileLoopBody(node.Body, start, exit); err != nil {
+			return err
+		}
+
+	case *ast.ForStatement:
+		if err := c.Compile(node.Iterable); err != nil {
+			return err
+		}
+		c.emit(code.OpIter)
+
+		// The iterator lives in a slot of its own, named so that programs
+		// cannot refer to it, which nested loops keep apart by depth.
+		loops := len(c.scopes[c.scopeIndex].loops)
+		iterator := c.symbolTable.Define(fmt.Sprintf("for %d", loops))
+		c.storeSymbol(iterator)
+
+		start := len(c.currentInstructions())
+		c.loadSymbol(iterator)
+		exit := c.emit(code.OpIterNext, 9999)
+
+		// The variable, like the names the body defines, is only visible
+		// in the loop.
+		c.symbolTable.EnterBlock()
+		c.storeSymbol(c.symbolTable.Define(node.Variable.Value))
+
+		err := c.compileLoopBody(node.Body, start, exit)
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
+		}
+
+	case *ast.BreakStatement:
+		if err := c.leaveTries(len(c.scopes[c.scopeIndex].loops)); err != nil {
+			return err
+		}
+		loop := c.currentLoop()
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		if err := c.leaveTries(len(c.scopes[c.scopeIndex].loops)); err != nil {
+			return err
+		}
+		loop := c.currentLoop()
+		loop.continues = append(loop.continues, c.emit(code.OpJump, 9999))
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			name := c.addConstant(&object.String{Value: node.Value})
+			c.emit(code.OpUndefined, name)
+			return nil
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInteger{Value: node.Big}
+		}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := prefixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.InfixExpression:
+		if node.Operator == token.AND || node.Operator == token.OR {
+			return c.compileLogical(node)
+		}
+
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		// Emit an `OpJumpNotTruthy` with a bogus value
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.compileBlockValue(node.Consequence); err != nil {
+			return err
+		}
+
+		// Emit an `OpJump` with a bogus value
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		afterConsequencePos := len(c.currentInstructions())
+		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileBlockValue(node.Alternative); err != nil {
+			return err
+		}
+
+		afterAlternativePos := len(c.currentInstructions())
+		c.changeOperand(jumpPos, afterAlternativePos)
+
+	case *ast.TryExpression:
+		return c.compileTry(node)
+
+	case *ast.MatchExpression:
+		return c.compileMatch(node)
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		keys := []ast.Expression{}
+		for k := range node.Pairs {
+			keys = append(keys, k)
+		}
+		// Sort the keys so the instructions are deterministic.
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		for _, k := range keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			if err := eval.CheckArity(1, 1, len(node.Arguments)); err != nil {
+				return c.Compile(&ast.ExpansionError{Call: node, Kind: string(err.Kind), Message: err.Message})
+			}
+			return c.compileQuote(node.Arguments[0])
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+
+		if len(node.Arguments) > 255 {
+			return fmt.Errorf("too many arguments in call: %d", len(node.Arguments))
+		}
+
+		op := code.OpCall
+		for _, a := range node.Arguments {
+			if _, ok := a.(*ast.SpreadExpression); ok {
+				op = code.OpCallSpread
+			}
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(op, len(node.Arguments))
+
+	case *ast.SpreadExpression:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSpread)
+
+	case *ast.ExpansionError:
+		// The error is raised like a rethrown exception, which keeps its
+		// kind.
+		err := &object.Error{Kind: object.ErrorKind(node.Kind), Message: node.Message,
+			Limit: object.LimitKind(node.Limit)}
+		c.emit(code.OpConstant, c.addConstant(&object.Exception{Err: err}))
+		c.emit(code.OpThrow)
+
+	case *ast.MacroLiteral:
+		// Macros are expanded before compilation; a literal left in the
+		// program has no runtime value.
+		c.emit(code.OpNull)
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+		GlobalNames:  c.symbolTable.Names(),
+	}
+}
+
+// hoist defines the names that the let, export and import statements of a
+// program or function body bind up front, so functions can refer to
+// variables that are bound later, just as they can with the evaluator. The
+// statements of nested blocks that share the scope, such as those of if
+// expressions and while loops, are included.
+func (c *Compiler) hoist(statements []ast.Statement) {
+	for _, s := range statements {
+		switch s := s.(type) {
+		case *ast.LetStatement:
+			for _, name := range s.Names() {
+				c.symbolTable.Define(name.Value)
+			}
+		case *ast.ExportStatement:
+			for _, name := range s.Statement.Names() {
+				c.symbolTable.Define(name.Value)
+			}
+		case *ast.ImportStatement:
+			c.symbolTable.Define(s.Name.Value)
+		case *ast.WhileStatement:
+			c.hoist(s.Body.Statements)
+		case *ast.ExpressionStatement:
+			c.hoistExpression(s.Expression)
+		}
+	}
+}
+
+// hoistExpression hoists the names bound in the blocks of an if or try
+// expression used as a statement. Catch blocks have scopes of their own.
+func (c *Compiler) hoistExpression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.IfExpression:
+		c.hoist(expr.Consequence.Statements)
+		if expr.Alternative != nil {
+			c.hoist(expr.Alternative.Statements)
+		}
+	case *ast.TryExpression:
+		c.hoist(expr.Block.Statements)
+		if expr.Finally != nil {
+			c.hoist(expr.Finally.Statements)
+		}
+	}
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	// A parameter that is a pattern takes its argument in a slot of its
+	// own, destructured once all parameters are defined. The vm passes
+	// null for missing arguments, which the defaults then replace.
+	for i, p := range node.Parameters {
+		switch p := p.(type) {
+		case *ast.Identifier:
+			c.symbolTable.Define(p.Value)
+		case *ast.DefaultPattern:
+			c.symbolTable.Define(p.Name.Value)
+		default:
+			c.symbolTable.Define(fmt.Sprintf("param %d", i))
+		}
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	var defaults []*ast.DefaultPattern
+	for i, p := range node.Parameters {
+		defaults = append(defaults, ast.Defaults(p)...)
+
+		switch p.(type) {
+		case *ast.Identifier, *ast.DefaultPattern:
+			continue
+		}
+
+		symbol, _ := c.symbolTable.Resolve(fmt.Sprintf("param %d", i))
+		c.loadSymbol(symbol)
+
+		outer := c.span
+		c.span = p.Span()
+		c.emit(code.OpDestructure, c.addConstant(&object.Quote{Node: p}))
+		c.span = outer
+
+		c.storeBindings(p)
+	}
+
+	if err := c.compileDefaults(defaults); err != nil {
+		return err
+	}
+
+	c.hoist(node.Body.Statements)
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if endsWithExpression(node.Body.Statements) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	localNames := c.symbolTable.Names()
+	scope := c.leaveScope()
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		c.loadCapture(s)
+		freeNames[i] = s.Name
+	}
+
+	numRequired, _ := ast.Arity(node.Parameters, node.Rest)
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		NumLocals:     len(localNames),
+		NumParameters: len(node.Parameters),
+		NumRequired:   numRequired,
+		Variadic:      node.Rest != nil,
+		Name:          node.Name,
+		Positions:     scope.positions,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+		Literal:       node,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+// compileBlockValue compiles a block whose value is used, as in an if
+// expression, leaving exactly one value on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if endsWithExpression(block.Statements) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+// compileLogical compiles && and || so that the right operand only runs
+// when the left one does not decide the result, which is always a boolean.
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	leftFalsy := c.emit(code.OpJumpNotTruthy, 9999)
+
+	var toFalse, toEnd []int
+	if node.Operator == token.AND {
+		toFalse = append(toFalse, leftFalsy)
+	} else {
+		c.emit(code.OpTrue)
+		toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+		c.changeOperand(leftFalsy, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	toFalse = append(toFalse, c.emit(code.OpJumpNotTruthy, 9999))
+	c.emit(code.OpTrue)
+	toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+
+	for _, pos := range toFalse {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	c.emit(code.OpFalse)
+
+	for _, pos := range toEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileAssign(node *ast.AssignStatement) error {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.ResolveBinding(target.Value)
+		if !ok || symbol.Scope == BuiltinScope {
+			name := c.addConstant(&object.String{Value: target.Value})
+			c.emit(code.OpUndefined, name)
+			return nil
+		}
+
+		// Loading the variable even when it is overwritten makes assigning
+		// to one that is not bound yet an error, as in the evaluator.
+		c.loadSymbol(symbol)
+		if operator == "" {
+			c.emit(code.OpPop)
+		}
+
+		if err := c.compileAssignedValue(operator, node.Value); err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if operator != "" {
+			c.emit(code.OpDupPair)
+			c.emit(code.OpIndex)
+		}
+
+		if err := c.compileAssignedValue(operator, node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex)
+
+	default:
+		return fmt.Errorf("cannot assign to %T", node.Target)
+	}
+
+	return nil
+}
+
+// compileAssignedValue compiles the right-hand side of an assignment and,
+// for a compound operator, combines it with the current value already on
+// the stack.
+func (c *Compiler) compileAssignedValue(operator string, value ast.Expression) error {
+	if err := c.Compile(value); err != nil {
+		return err
+	}
+
+	if operator != "" {
+		c.emit(infixOperators[operator])
+	}
+
+	return nil
+}
+
+// compileLoopBody compiles the body of a loop that starts at start and
+// leaves through the jump at exit, which is patched along with the jumps of
+// break and continue statements in the body.
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, start, exit int) error {
+	scope := &c.scopes[c.scopeIndex]
+	loop := &loop{}
+	scope.loops = append(scope.loops, loop)
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exit, end)
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, end)
+	}
+	for _, pos := range loop.continues {
+		c.changeOperand(pos, start)
+	}
+
+	return nil
+}
+
+// compileTry compiles a try expression. The finally block is compiled
+// once for every way out of the expression: after the block or the catch
+// block completes, when either raises an error, in which case the error is
+// raised again afterwards, and before the return, break and continue
+// statements inside them.
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	blocks := len(c.symbolTable.blocks)
+	handler := c.emit(code.OpTry, 9999)
+	if err := c.compileGuarded(node.Block, node.Finally, blocks); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	toEnd := []int{c.emit(code.OpJump, 9999)}
+
+	c.changeOperand(handler, len(c.currentInstructions()))
+
+	if node.Catch != nil {
+		// The parameter, like the names the catch block defines, is only
+		// visible in the catch block.
+		c.symbolTable.EnterBlock()
+		c.storeSymbol(c.symbolTable.Define(node.Param.Value))
+
+		var err error
+		if node.Finally == nil {
+			err = c.compileBlockValue(node.Catch)
+		} else {
+			handler = c.emit(code.OpTry, 9999)
+			err = c.compileGuarded(node.Catch, node.Finally, blocks)
+			c.emit(code.OpEndTry)
+		}
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
+		}
+
+		if node.Finally != nil {
+			if err := c.compileFinally(node.Finally); err != nil {
+				return err
+			}
+			toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+
+			c.changeOperand(handler, len(c.currentInstructions()))
+		}
+	}
+
+	// The exception the handler starts with stays on the stack while the
+	// finally block runs, to be raised again.
+	if node.Finally != nil {
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+
+	for _, pos := range toEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileGuarded compiles the value of a block run with the handler of a
+// try expression installed. blocks is the number of blocks of the symbol
+// table open outside the try expression.
+func (c *Compiler) compileGuarded(block, finally *ast.BlockStatement, blocks int) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, &try{finally: finally, loops: len(scope.loops), blocks: blocks})
+
+	err := c.compileBlockValue(block)
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+
+	return err
+}
+
+// compileFinally compiles a finally block, which may be nil, for its
+// effects only.
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+
+	return c.Compile(finally)
+}
+
+// leaveTries emits the code leaving the try expressions a statement jumps
+// out of: it removes their handlers and runs their finally blocks,
+// innermost first. Those are the try expressions entered inside at least
+// loops loops, so all of them for a return and the ones inside the
+// innermost loop for a break or continue.
+func (c *Compiler) leaveTries(loops int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= 0 && tries[i].loops >= loops; i-- {
+		// A finally block runs without the handler of its own try
+		// expression.
+		c.scopes[c.scopeIndex].tries = tries[:i]
+
+		c.emit(code.OpEndTry)
+
+		// The finally block does not see the names of the blocks, such as
+		// a catch block, the statement leaves.
+		suspended := c.symbolTable.suspendBlocks(tries[i].blocks)
+		err := c.compileFinally(tries[i].finally)
+		c.symbolTable.resumeBlocks(suspended)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compileMatch compiles a match expression. The arms test the subject one
+// after the other, and an arm that matches stores the values its pattern
+// binds before it checks the guard.
+func (c *Compiler) compileMatch(node *ast.MatchExpression) error {
+	if err := c.Compile(node.Subject); err != nil {
+		return err
+	}
+
+	// The subject lives in a slot of its own while the arms test it, named
+	// so that programs cannot refer to it, which nested matches keep apart
+	// by depth.
+	depth := c.scopes[c.scopeIndex].matches
+	subject := c.symbolTable.Define(fmt.Sprintf("match %d", depth))
+	c.storeSymbol(subject)
+
+	c.scopes[c.scopeIndex].matches++
+	defer func() { c.scopes[c.scopeIndex].matches-- }()
+
+	var toEnd []int
+	for _, arm := range node.Arms {
+		// The pattern is kept among the constants as a quoted node, like
+		// the templates of quote calls.
+		pattern := c.addConstant(&object.Quote{Node: arm.Pattern})
+		c.loadSymbol(subject)
+		c.emit(code.OpMatch, pattern)
+		toNext := []int{c.emit(code.OpJumpNotTruthy, 9999)}
+
+		// The names an arm binds are only visible in its guard and body.
+		c.symbolTable.EnterBlock()
+		err := c.compileArm(arm, &toNext)
+		c.symbolTable.LeaveBlock()
+		if err != nil {
+			return err
+		}
+		toEnd = append(toEnd, c.emit(code.OpJump, 9999))
+
+		for _, pos := range toNext {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+	}
+
+	c.loadSymbol(subject)
+	c.emit(code.OpNoMatch)
+
+	for _, pos := range toEnd {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	return nil
+}
+
+// compileArm compiles the bindings, guard and body of arm, adding the jump
+// taken when the guard fails to toNext.
+func (c *Compiler) compileArm(arm *ast.MatchArm, toNext *[]int) error {
+	if err := c.compileBindings(arm.Pattern); err != nil {
+		return err
+	}
+
+	if arm.Guard != nil {
+		if err := c.Compile(arm.Guard); err != nil {
+			return err
+		}
+		*toNext = append(*toNext, c.emit(code.OpJumpNotTruthy, 9999))
+	}
+
+	return c.Compile(arm.Body)
+}
+
+// compileDestructure destructures the value on the stack with pattern,
+// binding the names in it.
+func (c *Compiler) compileDestructure(pattern ast.Pattern) error {
+	c.emit(code.OpDestructure, c.addConstant(&object.Quote{Node: pattern}))
+	return c.compileBindings(pattern)
+}
+
+// compileBindings stores the values OpMatch or OpDestructure pushed for
+// the names pattern binds, and then the defaults of those that are null.
+func (c *Compiler) compileBindings(pattern ast.Pattern) error {
+	c.storeBindings(pattern)
+	return c.compileDefaults(ast.Defaults(pattern))
+}
+
+func (c *Compiler) storeBindings(pattern ast.Pattern) {
+	names := ast.Bindings(pattern)
+	for i := len(names) - 1; i >= 0; i-- {
+		c.storeSymbol(c.symbolTable.Define(names[i].Value))
+	}
+}
+
+// compileDefaults binds the default of each element whose name is bound
+// to null, in order.
+func (c *Compiler) compileDefaults(defaults []*ast.DefaultPattern) error {
+	for _, element := range defaults {
+		symbol, _ := c.symbolTable.Resolve(element.Name.Value)
+		c.loadSymbol(symbol)
+		c.emit(code.OpNull)
+		c.emit(code.OpEqual)
+		toSkip := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.Compile(element.Default); err != nil {
+			return err
+		}
+		c.storeSymbol(symbol)
+
+		c.changeOperand(toSkip, len(c.currentInstructions()))
+	}
+
+	return nil
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+// compileQuote compiles quote(node). Each unquote call inside node is
+// replaced by a numbered placeholder and its argument is compiled so that
+// OpQuote finds the values on the stack.
+func (c *Compiler) compileQuote(node ast.Node) error {
+	unquoted := []ast.Expression{}
+	placeholders := map[ast.Node]bool{}
+	nested := false
+
+	template := ast.Modify(node, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || call.Function.TokenLiteral() != "unquote" || len(call.Arguments) != 1 {
+			return node
+		}
+
+		// Modify works bottom-up, so unquote calls nested in this one have
+		// already been replaced.
+		ast.Modify(call.Arguments[0], func(inner ast.Node) ast.Node {
+			nested = nested || placeholders[inner]
+			return inner
+		})
+
+		index := len(unquoted)
+		unquoted = append(unquoted, call.Arguments[0])
+
+		placeholder := &ast.IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", index)},
+			Value: int64(index),
+		}
+		placeholders[placeholder] = true
+
+		return &ast.CallExpression{
+			Token:     call.Token,
+			Function:  call.Function,
+			Arguments: []ast.Expression{placeholder},
+			Rparen:    call.Rparen,
+		}
+	})
+
+	if nested {
+		return fmt.Errorf("unquote nested inside unquote is not supported by the compiler")
+	}
+
+	if len(unquoted) > 255 {
+		return fmt.Errorf("too many unquote calls in quote: %d", len(unquoted))
+	}
+
+	for _, arg := range unquoted {
+		if err := c.Compile(arg); err != nil {
+			return err
+		}
+	}
+
+	quote := c.addConstant(&object.Quote{Node: template})
+	c.emit(code.OpQuote, quote, len(unquoted))
+
+	return nil
+}
+
+func endsWithExpression(statements []ast.Statement) bool {
+	if len(statements) == 0 {
+		return false
+	}
+
+	_, ok := statements[len(statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func endsWithLoop(statements []ast.Statement) bool {
+	if len(statements) == 0 {
+		return false
+	}
+
+	switch statements[len(statements)-1].(type) {
+	case *ast.WhileStatement, *ast.ForStatement:
+		return true
+	}
+	return false
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+// loadCapture pushes the variable a closure being created captures. Locals
+// and free variables are captured by reference, so assignments made through
+// the closure and through its enclosing function are seen by both.
+func (c *Compiler) loadCapture(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands...)
+
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.scopes[c.scopeIndex].positions[pos] = c.span
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// checkOperands records in c.overflow if an operand does not fit op.
+func (c *Compiler) checkOperands(op code.Opcode, operands ...int) {
+	if c.overflow != nil {
+		return
+	}
+
+	if err := code.CheckOperands(op, operands...); err != nil {
+		c.overflow = fmt.Errorf("program too large: %s", err)
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	updatedInstructions := append(c.currentInstructions(), ins...)
+
+	c.scopes[c.scopeIndex].instructions = updatedInstructions
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	new := old[:last.Position]
+
+	delete(c.scopes[c.scopeIndex].positions, last.Position)
+	c.scopes[c.scopeIndex].instructions = new
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.checkOperands(op, operand)
+
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, newCompilationScope())
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() CompilationScope {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope
+}
@@ -0,0 +1,468 @@
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestCompiler(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let one = 1;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "while (true) { if (false) { break; } continue; }",
+			expectedConstants: []interface{}{},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 23),
+				// 0004
+				code.Make(code.OpFalse),
+				// 0005
+				code.Make(code.OpJumpNotTruthy, 15),
+				// 0008
+				code.Make(code.OpJump, 23),
+				// 0011
+				code.Make(code.OpNull),
+				// 0012
+				code.Make(code.OpJump, 16),
+				// 0015
+				code.Make(code.OpNull),
+				// 0016
+				code.Make(code.OpPop),
+				// 0017
+				code.Make(code.OpJump, 0),
+				// 0020
+				code.Make(code.OpJump, 0),
+				// 0023: a loop ending the program has the value null
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "let f = fn() { g() }; let g = fn() { 1 };",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input: "fn(a) { fn(b) { a + b } }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a) { fn() { a += 1 } }",
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpSetFree, 0),
+					code.Make(code.OpReturn),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCaptureLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let h = {}; h[1] = 2; h[1] *= 3;",
+			expectedConstants: []interface{}{1, 2, 1, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpHash, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpDupPair),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpMul),
+				code.Make(code.OpSetIndex),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input: `let f = fn() { m }; import m "lib/m"; export let x = m;`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpReturnValue),
+				},
+				"lib/m",
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpImport, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+				code.Make(code.OpSetGlobal, 2),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			input:             "try { 1 } catch (e) { 2 } finally { 3 }",
+			expectedConstants: []interface{}{1, 3, 2, 3, 3},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 14),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 36),
+				// 0014: the error raised in the block is caught
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpTry, 31),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpEndTry),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 36),
+				// 0031: the catch block raised an error
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpPop),
+				code.Make(code.OpThrow),
+				// 0036
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { try { return 1 } finally { 2 } }",
+			expectedConstants: []interface{}{
+				1, 2, 2, 2,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpTry, 21),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpEndTry),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpReturnValue),
+					code.Make(code.OpNull),
+					code.Make(code.OpEndTry),
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpPop),
+					code.Make(code.OpJump, 26),
+					// 0021
+					code.Make(code.OpConstant, 3),
+					code.Make(code.OpPop),
+					code.Make(code.OpThrow),
+					// 0026
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 4, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn([a, b = 1]) { b }",
+			expectedConstants: []interface{}{
+				"QUOTE([a, b = 1])",
+				1,
+				[]code.Instructions{
+					// 0000: the argument is taken apart
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpDestructure, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpSetLocal, 2),
+					// 0009: b gets its default if it is null
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpNull),
+					code.Make(code.OpEqual),
+					code.Make(code.OpJumpNotTruthy, 21),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetLocal, 1),
+					// 0021
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a, ...r) { r }(1, ...[])",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpSpread),
+				code.Make(code.OpCallSpread, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "missing",
+			expectedConstants: []interface{}{"missing"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpUndefined, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "quote(1 + unquote(2))",
+			expectedConstants: []interface{}{2, "QUOTE((1 + unquote(0)))"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpQuote, 1, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerPositions(t *testing.T) {
+	input := "let a = 1;\na + true"
+
+	program := parse(input)
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := compiler.Bytecode()
+
+	// OpConstant 0, OpSetGlobal 0, OpGetGlobal 0, OpTrue, OpAdd
+	add := 3 + 3 + 3 + 1
+	if code.Opcode(bytecode.Instructions[add]) != code.OpAdd {
+		t.Fatalf("expected OpAdd at %d. got=\n%s", add, bytecode.Instructions)
+	}
+
+	pos := bytecode.Positions[add].Start
+	if pos.Line != 2 || pos.Column != 1 {
+		t.Errorf("wrong position for OpAdd. got=%s", pos)
+	}
+}
+
+func TestNestedUnquoteIsRejected(t *testing.T) {
+	program := parse("quote(unquote(quote(unquote(1))))")
+
+	err := New().Compile(program)
+	if err == nil {
+		t.Fatalf("expected compiler error")
+	}
+}
+
+func TestOperandOverflow(t *testing.T) {
+	var locals, constants strings.Builder
+
+	locals.WriteString("fn() {")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&locals, "let v%d = %d;", i, i)
+	}
+	locals.WriteString("}")
+
+	for i := 0; i < 70000; i++ {
+		fmt.Fprintf(&constants, "%d;", i)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{locals.String(), "program too large: operand 256 of OpSetLocal out of range, the limit is 255"},
+		{constants.String(), "program too large: operand 65536 of OpConstant out of range, the limit is 65535"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if msg := testInstructions(tt.expectedInstructions, bytecode.Instructions); msg != "" {
+			t.Errorf("%s: %s", tt.input, msg)
+		}
+
+		if msg := testConstants(tt.expectedConstants, bytecode.Constants); msg != "" {
+			t.Errorf("%s: %s", tt.input, msg)
+		}
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+
+	return out
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) string {
+	concatted := concatInstructions(expected)
+
+	if actual.String() != concatted.String() {
+		return "wrong instructions.\nwant=\n" + concatted.String() + "got=\n" + actual.String()
+	}
+
+	return ""
+}
+
+func testConstants(expected []interface{}, actual []object.Object) string {
+	if len(expected) != len(actual) {
+		return "wrong number of constants"
+	}
+
+	var errs []string
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				errs = append(errs, "wrong integer constant: "+actual[i].Inspect())
+			}
+
+		case string:
+			got := actual[i].Inspect()
+			if quote, ok := actual[i].(*object.Quote); ok {
+				got = "QUOTE(" + quote.Node.String() + ")"
+			}
+			if got != constant {
+				errs = append(errs, "wrong constant: want="+constant+", got="+got)
+			}
+
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				errs = append(errs, "constant is not a function: "+actual[i].Inspect())
+				continue
+			}
+			if err := testInstructions(constant, fn.Instructions); err != "" {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return strings.Join(errs, "\n")
+}
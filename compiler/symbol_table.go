@@ -0,0 +1,194 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	// names holds the name of each global or local slot.
+	names []string
+	// blocks are the nested scopes being compiled, innermost last.
+	blocks []*block
+
+	FreeSymbols []Symbol
+}
+
+// block is a scope nested in a function or the program, such as a match
+// arm. The names it defines get slots of their own and hide any symbols of
+// the same name until the block ends.
+type block struct {
+	symbols map[string]Symbol
+	hidden  map[string]Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds name in this table. Like let in the evaluator, defining a
+// name that already exists in the same scope reuses its slot, so closures
+// compiled earlier see the new value.
+func (s *SymbolTable) Define(name string) Symbol {
+	symbol, ok := s.store[name]
+	n := len(s.blocks)
+	if n == 0 || s.blocks[n-1].defines(name) {
+		if ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+			return symbol
+		}
+	}
+
+	symbol = Symbol{Name: name, Index: len(s.names)}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	if n > 0 {
+		b := s.blocks[n-1]
+		if outer, ok := s.store[name]; ok {
+			b.hidden[name] = outer
+		}
+		b.symbols[name] = symbol
+	}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// EnterBlock starts a block, in which Define gives names new slots.
+func (s *SymbolTable) EnterBlock() {
+	s.resumeBlocks([]*block{{symbols: map[string]Symbol{}}})
+}
+
+// LeaveBlock ends the innermost block, making the symbols it hid visible
+// again.
+func (s *SymbolTable) LeaveBlock() {
+	s.suspendBlocks(len(s.blocks) - 1)
+}
+
+// suspendBlocks leaves the blocks after the first n as if they had ended
+// and returns them, so code outside them can be compiled in between.
+func (s *SymbolTable) suspendBlocks(n int) []*block {
+	suspended := s.blocks[n:]
+	s.blocks = s.blocks[:n]
+
+	for i := len(suspended) - 1; i >= 0; i-- {
+		b := suspended[i]
+		for name := range b.symbols {
+			if symbol, ok := b.hidden[name]; ok {
+				s.store[name] = symbol
+			} else {
+				delete(s.store, name)
+			}
+		}
+	}
+
+	return suspended
+}
+
+// resumeBlocks enters blocks again, innermost last.
+func (s *SymbolTable) resumeBlocks(blocks []*block) {
+	for _, b := range blocks {
+		b.hidden = map[string]Symbol{}
+		for name, symbol := range b.symbols {
+			if outer, ok := s.store[name]; ok {
+				b.hidden[name] = outer
+			}
+			s.store[name] = symbol
+		}
+		s.blocks = append(s.blocks, b)
+	}
+}
+
+func (b *block) defines(name string) bool {
+	_, ok := b.symbols[name]
+	return ok
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+
+	return obj, ok
+}
+
+// ResolveBinding is like Resolve but looks past the name a function is
+// bound to inside its own body, to the let binding that an assignment to
+// the name updates.
+func (s *SymbolTable) ResolveBinding(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if ok && obj.Scope != FunctionScope || s.Outer == nil {
+		return obj, ok
+	}
+
+	obj, ok = s.Outer.ResolveBinding(name)
+	if !ok || obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		return obj, ok
+	}
+
+	return s.defineFree(obj), true
+}
+
+// Names returns the names of the global or local slots defined in this
+// table, indexed by slot.
+func (s *SymbolTable) Names() []string {
+	return append([]string{}, s.names...)
+}
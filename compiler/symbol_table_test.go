@@ -0,0 +1,125 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+
+	a := global.Define("a")
+	if a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+
+	b := global.Define("b")
+	if b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+
+	c := local.Define("c")
+	if c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+
+	d := local.Define("d")
+	if d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+
+	again := global.Define("a")
+	if again != expected["a"] {
+		t.Errorf("redefining a should reuse its slot. got=%+v", again)
+	}
+}
+
+func TestDefineInBlock(t *testing.T) {
+	local := NewEnclosedSymbolTable(NewSymbolTable())
+	local.Define("a")
+
+	local.EnterBlock()
+	inner := local.Define("a")
+	if expected := (Symbol{Name: "a", Scope: LocalScope, Index: 1}); inner != expected {
+		t.Errorf("a in the block should get a new slot. want=%+v, got=%+v", expected, inner)
+	}
+	if again := local.Define("a"); again != inner {
+		t.Errorf("redefining a in the block should reuse its slot. got=%+v", again)
+	}
+	b := local.Define("b")
+	local.LeaveBlock()
+
+	if a, _ := local.Resolve("a"); a.Index != 0 {
+		t.Errorf("a should resolve to its slot outside the block again. got=%+v", a)
+	}
+	if symbol, ok := local.Resolve("b"); ok {
+		t.Errorf("b should not resolve outside the block. got=%+v", symbol)
+	}
+
+	names := local.Names()
+	if len(names) != 3 || names[0] != "a" || names[1] != "a" || names[b.Index] != "b" {
+		t.Errorf("wrong slot names. got=%v", names)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: FreeScope, Index: 0}},
+		{"e", Symbol{Name: "e", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		result, ok := secondLocal.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v",
+				tt.name, tt.expected, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0].Name != "c" {
+		t.Errorf("wrong free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+
+	if _, ok := secondLocal.Resolve("missing"); ok {
+		t.Errorf("name missing resolved, but was expected not to")
+	}
+}
+
+func TestDefineResolveBuiltinsAndFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineFunctionName("f")
+
+	if s, _ := local.Resolve("len"); s != (Symbol{Name: "len", Scope: BuiltinScope, Index: 0}) {
+		t.Errorf("len resolved wrongly. got=%+v", s)
+	}
+
+	if s, _ := local.Resolve("f"); s != (Symbol{Name: "f", Scope: FunctionScope, Index: 0}) {
+		t.Errorf("f resolved wrongly. got=%+v", s)
+	}
+}
@@ -0,0 +1,119 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"strings"
+)
+
+// evalAssignStatement updates a variable where it is bound, or an element
+// of an array or hash in place. Like let, it has no value of its own.
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		current, ok := env.Get(target.Value)
+		if !ok {
+			return newError(object.NameError, "identifier not found: "+target.Value)
+		}
+
+		value := evalAssignedValue(node, current, env)
+		if isError(value) {
+			return value
+		}
+
+		env.Assign(target.Value, value)
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if node.Operator != "=" {
+			current = evalIndexExpession(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := evalAssignedValue(node, current, env)
+		if isError(value) {
+			return value
+		}
+
+		if isNewKey(left, index) {
+			if err := charge(env, object.HashPairSize); err != nil {
+				return err
+			}
+		}
+
+		if err := setIndex(left, index, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// evalAssignedValue evaluates the right-hand side of an assignment and,
+// for a compound operator such as "+=", combines it with current.
+func evalAssignedValue(node *ast.AssignStatement, current object.Object, env *object.Environment) object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) || node.Operator == "=" {
+		return value
+	}
+
+	operator := strings.TrimSuffix(node.Operator, "=")
+	return allocate(env, evalInfixExpression(operator, current, value))
+}
+
+// isNewKey reports whether storing at index adds an entry to left, which
+// is the case for a hash without the key.
+func isNewKey(left, index object.Object) bool {
+	hash, ok := left.(*object.Hash)
+	if !ok {
+		return false
+	}
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return false
+	}
+
+	_, ok = hash.Pairs[key.HashKey()]
+	return !ok
+}
+
+// setIndex stores value at index in an array or hash. It returns an error,
+// or nil if the assignment succeeded.
+func setIndex(left, index, value object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if index.Type() != object.INTEGER_OBJ {
+			return newError(object.TypeError, "array index must be INTEGER, got %s", index.Type())
+		}
+
+		idx, ok := index.(*object.Integer)
+		if !ok || idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError(object.IndexError, "index %s out of range for array of length %d",
+				index.Inspect(), len(left.Elements))
+		}
+
+		left.Elements[idx.Value] = value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.TypeError, "unusable as hash key: %s", index.Type())
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+	default:
+		return newError(object.TypeError, "index assignment not supported: %s", left.Type())
+	}
+
+	return nil
+}
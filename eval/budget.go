@@ -0,0 +1,174 @@
+package eval
+
+import (
+	"context"
+	"monkey/ast"
+	"monkey/object"
+	"time"
+)
+
+// DefaultMaxCallDepth bounds the nesting of Monkey calls when no other
+// limit is given. Every call uses several Go frames, and going much deeper
+// would overflow the Go stack and crash the process.
+const DefaultMaxCallDepth = 10000
+
+// contextCheckInterval is how many steps pass between checks of the
+// context, which are comparatively expensive.
+const contextCheckInterval = 1024
+
+// Limits bound an evaluation started with EvalContext. Zero fields mean no
+// limit, except that MaxCallDepth defaults to DefaultMaxCallDepth.
+type Limits struct {
+	MaxSteps     int64 // nodes evaluated
+	MaxCallDepth int   // nested Monkey function calls
+	MaxAllocated int64 // approximate bytes of new strings, arrays, hashes and big integers
+	Timeout      time.Duration
+}
+
+// EvalContext is like Eval but stops with an error whose Limit is set once
+// the evaluation exceeds limits or ctx is done.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	budget, cancel := NewBudget(ctx, limits)
+	defer cancel()
+
+	return EvalBudget(node, env, budget)
+}
+
+// ApplyContext is like Apply but enforces limits as EvalContext does.
+func ApplyContext(ctx context.Context, fn object.Object, args []object.Object, limits Limits) object.Object {
+	budget, cancel := NewBudget(ctx, limits)
+	defer cancel()
+
+	return ApplyBudget(fn, args, budget)
+}
+
+// NewBudget returns a budget that enforces limits and stops once ctx is
+// done. Call cancel when the budget is no longer used. Hosts that want to
+// inspect what an evaluation used pass the budget to EvalBudget.
+func NewBudget(ctx context.Context, limits Limits) (budget *object.Budget, cancel context.CancelFunc) {
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	maxCallDepth := limits.MaxCallDepth
+	if maxCallDepth == 0 {
+		maxCallDepth = DefaultMaxCallDepth
+	}
+
+	budget = &object.Budget{
+		Context:      ctx,
+		MaxSteps:     limits.MaxSteps,
+		MaxCallDepth: maxCallDepth,
+		MaxAllocated: limits.MaxAllocated,
+	}
+	return budget, cancel
+}
+
+// EvalBudget evaluates node in env, charging the work to budget.
+func EvalBudget(node ast.Node, env *object.Environment, budget *object.Budget) object.Object {
+	previous := env.Budget()
+	env.SetBudget(budget)
+	defer env.SetBudget(previous)
+
+	return Eval(node, env)
+}
+
+// ApplyBudget calls fn like Apply, charging the work to budget.
+func ApplyBudget(fn object.Object, args []object.Object, budget *object.Budget) object.Object {
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return Apply(fn, args)
+	}
+
+	previous := function.Env.Budget()
+	function.Env.SetBudget(budget)
+	defer function.Env.SetBudget(previous)
+
+	return Apply(fn, args)
+}
+
+// step charges one evaluation step to the budget of env and reports an
+// error if a limit has been reached.
+func step(env *object.Environment) *object.Error {
+	budget := env.Budget()
+	if budget == nil {
+		return nil
+	}
+
+	budget.Steps++
+	if budget.MaxSteps > 0 && budget.Steps > budget.MaxSteps {
+		return limitError(object.StepLimit, "step limit of %d exceeded", budget.MaxSteps)
+	}
+
+	if budget.Context != nil && budget.Steps%contextCheckInterval == 0 {
+		switch budget.Context.Err() {
+		case nil:
+		case context.DeadlineExceeded:
+			return limitError(object.DeadlineLimit, "deadline exceeded")
+		default:
+			return limitError(object.CanceledLimit, "evaluation canceled")
+		}
+	}
+
+	return nil
+}
+
+// enterCall records a Monkey call in the budget of env, attaching the
+// default budget if there is none so plain Eval cannot overflow the stack
+// either. The caller must call leaveCall once the call returns.
+func enterCall(env *object.Environment) (*object.Budget, *object.Error) {
+	budget := env.Budget()
+	if budget == nil {
+		budget = &object.Budget{MaxCallDepth: DefaultMaxCallDepth}
+		env.SetBudget(budget)
+	}
+
+	if budget.MaxCallDepth > 0 && budget.Depth >= budget.MaxCallDepth {
+		return nil, limitError(object.CallDepthLimit,
+			"call depth limit of %d exceeded", budget.MaxCallDepth)
+	}
+
+	budget.Depth++
+	return budget, nil
+}
+
+func leaveCall(budget *object.Budget) {
+	budget.Depth--
+}
+
+// allocate charges the size of obj, which the caller just created, to the
+// budget of env. It returns obj, or an error if the allocation limit has
+// been exceeded.
+func allocate(env *object.Environment, obj object.Object) object.Object {
+	if err := charge(env, object.SizeOf(obj)); err != nil {
+		return err
+	}
+
+	return obj
+}
+
+// charge adds size bytes, such as those by which an object grew, to the
+// allocations of the budget of env, and reports an error if the allocation
+// limit has been exceeded.
+func charge(env *object.Environment, size int64) *object.Error {
+	budget := env.Budget()
+	if budget == nil {
+		return nil
+	}
+
+	budget.Allocated += size
+	if budget.MaxAllocated > 0 && budget.Allocated > budget.MaxAllocated {
+		return limitError(object.MemoryLimit,
+			"allocation limit of %d bytes exceeded", budget.MaxAllocated)
+	}
+
+	return nil
+}
+
+func limitError(limit object.LimitKind, format string, a ...interface{}) *object.Error {
+	err := NewError(object.LimitError, format, a...)
+	err.Limit = limit
+	return err
+}
@@ -0,0 +1,212 @@
+package eval
+
+import (
+	"context"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+	"time"
+)
+
+const slowFibonacci = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(35);
+`
+
+func TestEvalContextLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		input    string
+		limits   Limits
+		limit    object.LimitKind
+		expected string
+	}{
+		{
+			context.Background(),
+			"let f = fn(n) { f(n + 1) }; f(0)",
+			Limits{MaxSteps: 1000},
+			object.StepLimit,
+			"step limit of 1000 exceeded",
+		},
+		{
+			context.Background(),
+			"while (true) { }",
+			Limits{MaxSteps: 1000},
+			object.StepLimit,
+			"step limit of 1000 exceeded",
+		},
+		{
+			context.Background(),
+			"while (true) { try { while (true) { } } catch (e) { } finally { } }",
+			Limits{MaxSteps: 1000},
+			object.StepLimit,
+			"step limit of 1000 exceeded",
+		},
+		{
+			context.Background(),
+			"let f = fn() { f() }; f()",
+			Limits{MaxCallDepth: 50},
+			object.CallDepthLimit,
+			"call depth limit of 50 exceeded",
+		},
+		{
+			context.Background(),
+			"let f = fn() { f() }; f()",
+			Limits{},
+			object.CallDepthLimit,
+			"call depth limit of 10000 exceeded",
+		},
+		{
+			context.Background(),
+			slowFibonacci,
+			Limits{Timeout: 10 * time.Millisecond},
+			object.DeadlineLimit,
+			"deadline exceeded",
+		},
+		{
+			context.Background(),
+			`let grow = fn(s) { grow(s + s) }; grow("x")`,
+			Limits{MaxAllocated: 1 << 20},
+			object.MemoryLimit,
+			"allocation limit of 1048576 bytes exceeded",
+		},
+		{
+			context.Background(),
+			"let fill = fn(a, n) { if (n == 0) { a } else { fill(push(a, n), n - 1) } }; fill([0], 5000)",
+			Limits{MaxAllocated: 100000},
+			object.MemoryLimit,
+			"allocation limit of 100000 bytes exceeded",
+		},
+		{
+			context.Background(),
+			"let h = {}; let i = 0; while (true) { h[i] = i; h[0] = i; i += 1 }",
+			Limits{MaxAllocated: 100000},
+			object.MemoryLimit,
+			"allocation limit of 100000 bytes exceeded",
+		},
+		{
+			context.Background(),
+			"let f = fn(...xs) { xs }; let a = [1]; while (true) { a = f(...a, ...a) }",
+			Limits{MaxAllocated: 1 << 20},
+			object.MemoryLimit,
+			"allocation limit of 1048576 bytes exceeded",
+		},
+		{
+			context.Background(),
+			"let a = [1, 2, 3]; while (true) { let [x, ...r] = a; match (a) { [y, ...s] => s } }",
+			Limits{MaxAllocated: 100000},
+			object.MemoryLimit,
+			"allocation limit of 100000 bytes exceeded",
+		},
+		{
+			context.Background(),
+			`let s = "abcdefghij"; while (true) { for (c in s) {} }`,
+			Limits{MaxAllocated: 100000},
+			object.MemoryLimit,
+			"allocation limit of 100000 bytes exceeded",
+		},
+		{
+			context.Background(),
+			`let h = {1: 1, "a": 2}; while (true) { for (k in h) {} }`,
+			Limits{MaxAllocated: 100000},
+			object.MemoryLimit,
+			"allocation limit of 100000 bytes exceeded",
+		},
+		{
+			context.Background(),
+			"let i = 0; while (true) { let i = i + 1; }",
+			Limits{Timeout: 10 * time.Millisecond},
+			object.DeadlineLimit,
+			"deadline exceeded",
+		},
+		{
+			canceled,
+			slowFibonacci,
+			Limits{},
+			object.CanceledLimit,
+			"evaluation canceled",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+
+		evaluated := EvalContext(tt.ctx, program, env, tt.limits)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("%q: no error object returned. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Limit != tt.limit {
+			t.Errorf("%q: wrong limit. want=%q, got=%q", tt.input, tt.limit, errObj.Limit)
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("%q: wrong message. want=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+		if !errObj.Span.IsValid() {
+			t.Errorf("%q: error has no position", tt.input)
+		}
+		if env.Budget() != nil {
+			t.Errorf("%q: budget was not detached", tt.input)
+		}
+	}
+}
+
+func TestEvalContextWithinLimits(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	evaluated := EvalContext(context.Background(), program, object.NewEnvironment(),
+		Limits{MaxSteps: 100000, MaxCallDepth: 101, Timeout: time.Minute})
+	testIntegerObject(t, evaluated, 100)
+}
+
+func TestEvalDefaultCallDepth(t *testing.T) {
+	evaluated := testEval("let f = fn() { f() }; f()")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Limit != object.CallDepthLimit {
+		t.Errorf("wrong limit. got=%q", errObj.Limit)
+	}
+}
+
+func TestEvalBudgetCountsAllocations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`1 + 2`, 0},
+		{`"ab" + "cd"`, 2*(16+16+2) + (16 + 16 + 4)},
+		{`[1, 2, 3]`, 16 + 24 + 3*16},
+		{`{1: 2}`, 16 + 64},
+		{`rest([1, 2])`, (16 + 24 + 2*16) + (16 + 24 + 16)},
+		{`let s = "abc"; len(s)`, 16 + 16 + 3},
+		{`99999999999999999999 * 10`, 16 + 24 + 2*8},
+		{`let h = {1: 2}; h[1] = 3; h[2] = 4`, (16 + 64) + 64},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		budget, cancel := NewBudget(context.Background(), Limits{})
+
+		EvalBudget(program, object.NewEnvironment(), budget)
+		cancel()
+
+		if budget.Allocated != tt.expected {
+			t.Errorf("%q: wrong allocation count. want=%d, got=%d",
+				tt.input, tt.expected, budget.Allocated)
+		}
+		if budget.Steps == 0 {
+			t.Errorf("%q: no steps counted", tt.input)
+		}
+	}
+}
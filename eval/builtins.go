@@ -2,120 +2,132 @@ package eval
 
 import (
 	"fmt"
+	"io"
 	"monkey/object"
+	"os"
+	"unicode/utf8"
 )
 
-var builtins = map[string]*object.Builtin{
-	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
-
-			return NULL
+// builtins is the table used when an identifier is not bound in the
+// environment. Its puts writes to os.Stdout.
+var builtins = NewBuiltins(os.Stdout)
+
+// NewBuiltins returns a fresh table of the builtin functions whose puts
+// writes to stdout. Interpreters that need their own output bind these in
+// the environment, where they take precedence over the shared table.
+func NewBuiltins(stdout io.Writer) map[string]*object.Builtin {
+	return map[string]*object.Builtin{
+		"puts": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				for _, arg := range args {
+					fmt.Fprintln(stdout, arg.Inspect())
+				}
+
+				return NULL
+			},
 		},
-	},
-	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-
-			default:
-				return newError("argument to `len` not supported, got %s",
-					args[0].Type())
-			}
+		"len": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(1, 1, len(args)); err != nil {
+					return err
+				}
+
+				switch arg := args[0].(type) {
+				case *object.String:
+					return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *object.Array:
+					return &object.Integer{Value: int64(len(arg.Elements))}
+
+				default:
+					return newError(object.ArgumentError, "argument to `len` not supported, got %s",
+						args[0].Type())
+				}
+			},
 		},
-	},
-	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s",
-					args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-
-			return NULL
+		"first": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(1, 1, len(args)); err != nil {
+					return err
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError(object.ArgumentError, "argument to `first` must be ARRAY, got %s",
+						args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				if len(arr.Elements) > 0 {
+					return arr.Elements[0]
+				}
+
+				return NULL
+			},
 		},
-	},
-	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s",
-					args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
-
-			return NULL
+		"last": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(1, 1, len(args)); err != nil {
+					return err
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError(object.ArgumentError, "argument to `last` must be ARRAY, got %s",
+						args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					return arr.Elements[length-1]
+				}
+
+				return NULL
+			},
 		},
-	},
-	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s",
-					args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
-			}
-
-			return NULL
+		"rest": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(1, 1, len(args)); err != nil {
+					return err
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError(object.ArgumentError, "argument to `rest` must be ARRAY, got %s",
+						args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]object.Object, length-1)
+					copy(newElements, arr.Elements[1:length])
+					return &object.Array{Elements: newElements}
+				}
+
+				return NULL
+			},
 		},
-	},
-	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s",
-					args[0].Type())
-			}
-
-			arr := args[0].(*object.Array)
-			elem := args[1]
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length+1)
-				copy(newElements, arr.Elements)
-				newElements[length] = elem
-				return &object.Array{Elements: newElements}
-			}
-
-			return NULL
+		"push": &object.Builtin{
+			Fn: func(args ...object.Object) object.Object {
+				if err := checkArity(2, 2, len(args)); err != nil {
+					return err
+				}
+
+				if args[0].Type() != object.ARRAY_OBJ {
+					return newError(object.ArgumentError, "argument to `push` must be ARRAY, got %s",
+						args[0].Type())
+				}
+
+				arr := args[0].(*object.Array)
+				elem := args[1]
+				length := len(arr.Elements)
+				if length > 0 {
+					newElements := make([]object.Object, length+1)
+					copy(newElements, arr.Elements)
+					newElements[length] = elem
+					return &object.Array{Elements: newElements}
+				}
+
+				return NULL
+			},
 		},
-	},
+	}
 }
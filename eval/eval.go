@@ -2,16 +2,21 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
 	"monkey/token"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
-	NULL        = &object.Null{}
-	TRUE        = &object.Boolean{Value: true}
-	FALSE       = &object.Boolean{Value: false}
-	ENVIRONMENT = object.NewEnvironment()
+	NULL     = &object.Null{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func isError(obj object.Object) bool {
@@ -22,6 +27,24 @@ func isError(obj object.Object) bool {
 }
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if err := step(env); err != nil {
+		if node != nil {
+			err.Span = node.Span()
+		}
+		return err
+	}
+
+	result := eval(node, env)
+
+	// The innermost node that produced an error is where it was raised.
+	if err, ok := result.(*object.Error); ok && !err.Span.IsValid() && node != nil {
+		err.Span = node.Span()
+	}
+
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
@@ -29,12 +52,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 	case *ast.StringLiteral:
-		return &object.String{Value: node.Value}
+		return allocate(env, &object.String{Value: node.Value})
+	case *ast.InterpolatedString:
+		values := evalExpressions(node.Parts, env)
+		if len(values) == 1 && isError(values[0]) {
+			return values[0]
+		}
+		return allocate(env, interpolate(values))
 	case *ast.Program:
 		return evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
@@ -46,6 +85,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		if node.Operator == token.AND || node.Operator == token.OR {
+			return evalLogicalExpression(node, env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -54,26 +97,54 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return allocate(env, evalInfixExpression(node.Operator, left, right))
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node.Statements, env)
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.LetStatement:
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 
+		if node.Pattern != nil {
+			return bindPattern(node.Pattern, val, env)
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
+	case *ast.ExpansionError:
+		err := NewError(object.ErrorKind(node.Kind), "%s", node.Message)
+		err.Limit = object.LimitKind(node.Limit)
+		return err
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Rest: node.Rest, Env: env, Body: body}
 	case *ast.CallExpression:
 		if node.Function.TokenLiteral() == "quote" {
+			if err := checkArity(1, 1, len(node.Arguments)); err != nil {
+				return err
+			}
 			return quote(node.Arguments[0], env)
 		}
 
@@ -87,13 +158,24 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		result := applyFunction(function, args)
+		if _, ok := function.(*object.Builtin); ok {
+			result = allocate(env, result)
+		}
+		if err, ok := result.(*object.Error); ok {
+			if fn, ok := function.(*object.Function); ok {
+				frame := object.Frame{Function: fn.Name, Call: node.Span()}
+				err.Stack = append(err.Stack, frame)
+			}
+		}
+
+		return result
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocate(env, &object.Array{Elements: elements})
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -107,7 +189,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalIndexExpession(left, idx)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return allocate(env, evalHashLiteral(node, env))
 	}
 
 	return nil
@@ -121,8 +203,8 @@ func nativeBoolToBooleanObject(b bool) object.Object {
 	return FALSE
 }
 
-func newError(format string, a ...interface{}) object.Object {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind object.ErrorKind, format string, a ...interface{}) object.Object {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
 }
 
 func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
@@ -150,7 +232,8 @@ func evalBlockStatement(statements []ast.Statement, env *object.Environment) obj
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ ||
+				rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
 				return result
 			}
 		}
@@ -162,8 +245,19 @@ func evalBlockStatement(statements []ast.Statement, env *object.Environment) obj
 
 func evalExpressions(args []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
+	spreads := false
 
 	for _, e := range args {
+		if node, ok := e.(*ast.SpreadExpression); ok {
+			elements := evalSpread(node, env)
+			if len(elements) == 1 && isError(elements[0]) {
+				return elements
+			}
+			result = append(result, elements...)
+			spreads = true
+			continue
+		}
+
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -171,9 +265,52 @@ func evalExpressions(args []ast.Expression, env *object.Environment) []object.Ob
 		result = append(result, evaluated)
 	}
 
+	// Spreading copies the elements into the argument list, which is
+	// charged like an array of them.
+	if spreads {
+		if err := allocate(env, &object.Array{Elements: result}); isError(err) {
+			return []object.Object{err}
+		}
+	}
+
 	return result
 }
 
+// evalSpread returns the elements of the array a spread argument passes,
+// or an error as the only element.
+func evalSpread(node *ast.SpreadExpression, env *object.Environment) []object.Object {
+	value := Eval(node.Value, env)
+	if isError(value) {
+		return []object.Object{value}
+	}
+
+	elements, err := spread(value)
+	if err != nil {
+		err.Span = node.Span()
+		return []object.Object{err}
+	}
+	return elements
+}
+
+func spread(value object.Object) ([]object.Object, *object.Error) {
+	array, ok := value.(*object.Array)
+	if !ok {
+		return nil, newError(object.TypeError, "spread argument must be ARRAY, got %s", value.Type()).(*object.Error)
+	}
+
+	return array.Elements, nil
+}
+
+// interpolate joins the Inspect forms of values into a string.
+func interpolate(values []object.Object) object.Object {
+	var out strings.Builder
+	for _, value := range values {
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case token.BANG:
@@ -181,7 +318,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case token.MINUS:
 		return evaluateMinusPrefixExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -199,18 +336,27 @@ func evaluateBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evaluateMinusPrefixExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return evalBigIntegerNegation(right)
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return evalBigIntegerNegation(right)
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.TypeError, "unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == token.EQ:
@@ -218,15 +364,36 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	case operator == token.NEQ:
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated when the left one does not decide the result, which is always
+// a boolean.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if isTruthy(left) == (node.Operator == token.OR) {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	if operator != "+" {
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TypeError, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 
@@ -235,19 +402,108 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	return &object.String{Value: leftVal + rightVal}
 }
 
+// evalIntegerInfixExpression switches to big integer arithmetic when an
+// operand is a big integer or the result does not fit in an int64.
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+	if !leftOk || !rightOk {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
 
 	switch operator {
 	case token.PLUS:
-		return &object.Integer{Value: leftVal + rightVal}
+		result := leftVal + rightVal
+		if (leftVal >= 0) == (rightVal >= 0) && (result >= 0) != (leftVal >= 0) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
 	case token.MINUS:
-		return &object.Integer{Value: leftVal - rightVal}
+		result := leftVal - rightVal
+		if (leftVal >= 0) != (rightVal >= 0) && (result >= 0) != (leftVal >= 0) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
 	case token.ASTERISK:
-		return &object.Integer{Value: leftVal * rightVal}
+		result := leftVal * rightVal
+		if leftVal != 0 && (result/leftVal != rightVal || leftVal == -1 && rightVal == math.MinInt64) {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: result}
 	case token.SLASH:
+		if rightVal == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntegerInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case token.PERCENT:
+		if rightVal == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case token.AMPERSAND:
+		return &object.Integer{Value: leftVal & rightVal}
+	case token.PIPE:
+		return &object.Integer{Value: leftVal | rightVal}
+	case token.CARET:
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case token.SHIFT_RIGHT:
+		if rightVal < 0 {
+			return newError(object.ArithmeticError, "negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
+	case token.SHIFT_LEFT, token.POWER:
+		// Both overflow easily, so they are always computed as big
+		// integers and demoted.
+		return evalBigIntegerInfixExpression(operator, left, right)
+	case token.EQ:
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case token.NEQ:
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case token.GT:
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case token.LT:
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	default:
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression handles arithmetic and comparisons where at
+// least one operand is a float. Integer operands are converted to floats
+// first, so 1 + 0.5 is 1.5 and 1 == 1.0 is true.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case token.PLUS:
+		return &object.Float{Value: leftVal + rightVal}
+	case token.MINUS:
+		return &object.Float{Value: leftVal - rightVal}
+	case token.ASTERISK:
+		return &object.Float{Value: leftVal * rightVal}
+	case token.SLASH:
+		if rightVal == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case token.PERCENT:
+		if rightVal == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case token.POWER:
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case token.EQ:
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case token.NEQ:
@@ -256,8 +512,33 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 		return nativeBoolToBooleanObject(leftVal > rightVal)
 	case token.LT:
 		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInteger, *object.Float:
+		return true
+	default:
+		return false
+	}
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	default:
+		return obj.(*object.Float).Value
 	}
 }
 
@@ -267,13 +548,19 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 		return condition
 	}
 
+	var result object.Object
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		result = Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
-	} else {
+		result = Eval(ie.Alternative, env)
+	}
+
+	// A block ending in a statement without a value, such as an
+	// assignment, gives the if expression the value null.
+	if result == nil {
 		return NULL
 	}
+	return result
 }
 
 func isTruthy(obj object.Object) bool {
@@ -299,16 +586,52 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if ok {
 		return builtin
 	}
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NameError, "identifier not found: "+node.Value)
 
 }
 
+// Apply calls fn, which must be a Monkey or builtin function, with args
+// and returns its result. This is how host code invokes functions that a
+// program handed back to it.
+func Apply(fn object.Object, args []object.Object) object.Object {
+	result := applyFunction(fn, args)
+	if err, ok := result.(*object.Error); ok {
+		if fn, ok := fn.(*object.Function); ok {
+			err.Stack = append(err.Stack, object.Frame{Function: fn.Name})
+		}
+	}
+
+	return result
+}
+
 func applyFunction(obj object.Object, args []object.Object) object.Object {
 	switch fn := obj.(type) {
 	case *object.Function:
 		{
-			extendedEnv := extendFunctionEnv(fn, args)
+			min, max := ast.Arity(fn.Parameters, fn.Rest)
+			if err := checkArity(min, max, len(args)); err != nil {
+				return err
+			}
+
+			budget, err := enterCall(fn.Env)
+			if err != nil {
+				return err
+			}
+
+			extendedEnv, err := extendFunctionEnv(fn, args)
+			if err != nil {
+				leaveCall(budget)
+				return err
+			}
+
 			evaluated := Eval(fn.Body, extendedEnv)
+			leaveCall(budget)
+
+			// A body that ends in a statement such as let has no value.
+			if evaluated == nil {
+				return NULL
+			}
+
 			return unwrapReturnValue(evaluated)
 		}
 	case *object.Builtin:
@@ -317,18 +640,84 @@ func applyFunction(obj object.Object, args []object.Object) object.Object {
 		}
 
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(object.TypeError, "not a function: %s", fn.Type())
+	}
+}
+
+// checkArity returns an error if got arguments are too few or too many
+// for a function taking at least min and at most max of them. max is -1
+// if there is no limit.
+func checkArity(min, max, got int) *object.Error {
+	if got >= min && (max < 0 || got <= max) {
+		return nil
+	}
+
+	want := fmt.Sprint(min)
+	switch {
+	case max < 0:
+		want = "at least " + want
+	case max > min:
+		want = fmt.Sprintf("%d to %d", min, max)
 	}
+	return newError(object.ArgumentError, "wrong number of arguments: want=%s, got=%d", want, got).(*object.Error)
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
+	// Parameters without an argument have defaults, which are bound to
+	// null until all parameters are bound, so they can refer to any.
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		arg := object.Object(NULL)
+		if paramIdx < len(args) {
+			arg = args[paramIdx]
+		}
+
+		switch param := param.(type) {
+		case *ast.Identifier:
+			env.Set(param.Value, arg)
+		case *ast.DefaultPattern:
+			env.Set(param.Name.Value, arg)
+		}
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if n := len(fn.Parameters); len(args) > n {
+			rest = make([]object.Object, len(args)-n)
+			copy(rest, args[n:])
+		}
+		array := allocate(env, &object.Array{Elements: rest})
+		if err, ok := array.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(fn.Rest.Value, array)
 	}
 
-	return env
+	var defaults []*ast.DefaultPattern
+	for paramIdx, param := range fn.Parameters {
+		defaults = append(defaults, ast.Defaults(param)...)
+
+		switch param.(type) {
+		case *ast.Identifier, *ast.DefaultPattern:
+			continue
+		}
+
+		values, err := destructure(param, args[paramIdx])
+		if err != nil {
+			err.Span = param.Span()
+			return nil, err
+		}
+		if err := bindNames(param, values, env); err != nil {
+			return nil, err.(*object.Error)
+		}
+	}
+
+	if err := bindDefaults(defaults, env); err != nil {
+		return nil, err.(*object.Error)
+	}
+
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -343,23 +732,49 @@ func evalIndexExpession(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, index)
+	case left.Type() == object.EXCEPTION_OBJ:
+		return evalExceptionIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TypeError, "index operator not supported: %s", left.Type())
 	}
 }
 
 func evalArrayIndexExpression(array object.Object, idx object.Object) object.Object {
 	elements := array.(*object.Array).Elements
-	index := idx.(*object.Integer).Value
+	index, ok := idx.(*object.Integer)
 	max := int64(len(elements)) - 1
 
-	if index < 0 || index > max {
-		return &object.Null{}
+	if !ok || index.Value < 0 || index.Value > max {
+		return NULL
+	}
+
+	return elements[index.Value]
+}
+
+// evalStringIndexExpression returns the character at index as a string.
+// Strings are indexed by character, not by byte.
+func evalStringIndexExpression(str object.Object, idx object.Object) object.Object {
+	value := str.(*object.String).Value
+	index, ok := idx.(*object.Integer)
+
+	if ok && index.Value >= 0 && index.Value < int64(len(value)) {
+		n := index.Value
+		for i := range value {
+			if n == 0 {
+				_, size := utf8.DecodeRuneInString(value[i:])
+				return &object.String{Value: value[i : i+size]}
+			}
+			n--
+		}
 	}
 
-	return elements[index]
+	return NULL
 }
 
 func evalHashIndexExpression(hash object.Object, idx object.Object) object.Object {
@@ -367,7 +782,7 @@ func evalHashIndexExpression(hash object.Object, idx object.Object) object.Objec
 
 	key, ok := idx.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", idx.Type())
+		return newError(object.TypeError, "unusable as hash key: %s", idx.Type())
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
@@ -390,7 +805,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TypeError, "unusable as hash key: %s", key.Type())
 		}
 
 		value := Eval(v, env)
@@ -43,6 +43,99 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 2", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"99999999999999999999 / 3", "33333333333333333333"},
+		{"-99999999999999999999 / 7", "-14285714285714285714"},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)",
+			"15511210043330985984000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		big, ok := evaluated.(*object.BigInteger)
+		if !ok {
+			t.Errorf("%q: object is not BigInteger. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if big.Inspect() != tt.expected {
+			t.Errorf("%q: wrong value. want=%s, got=%s", tt.input, tt.expected, big.Inspect())
+		}
+	}
+
+	// Results that fit in an int64 again are plain integers.
+	testIntegerObject(t, testEval("99999999999999999999 - 99999999999999999990"), 9)
+	testIntegerObject(t, testEval("9223372036854775807 + 1 - 1"), 9223372036854775807)
+
+	testBooleans := []struct {
+		input    string
+		expected bool
+	}{
+		{"99999999999999999999 > 1", true},
+		{"1 < 99999999999999999999", true},
+		{"99999999999999999999 == 99999999999999999999", true},
+		{"99999999999999999999 != 99999999999999999998", true},
+		{"99999999999999999999 < 1.5e20", true},
+	}
+	for _, tt := range testBooleans {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+
+	testFloatObject(t, testEval("99999999999999999999 * 0.5"), 5e19)
+	testNullObject(t, testEval("[1, 2, 3][99999999999999999999]"))
+	testIntegerObject(t, testEval(`{99999999999999999999: 1, 2: 2}[99999999999999999998 + 1]`), 1)
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e3", 1000},
+		{"0.1 + 0.2", 0.30000000000000004},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"2 * 1.5", 3},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5e1", -15},
+		{"let avg = fn(a, b) { (a + b) / 2.0 }; avg(3, 4)", 3.5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+
+	// Integer arithmetic stays integral.
+	testIntegerObject(t, testEval("7 / 2"), 3)
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("Object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -82,6 +175,11 @@ func TestBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.1 + 0.2 == 0.3", false},
 	}
 
 	for _, tt := range testing {
@@ -136,6 +234,17 @@ func TestIfElseExpression(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
+		{"let f = fn(n) { if (n < 0) { -1 } else if (n == 0) { 0 } else if (n < 10) { 1 } else { 2 } }; f(-5) + f(0) + f(5) + f(50)", 2},
+		{"null", nil},
+		{"if (null) { 10 }", nil},
+		{"if (null == null) { 10 }", 10},
+		{"if ([1, 2][5] == null) { 10 }", 10},
+		{`if ("ab"[5] == null) { 10 }`, 10},
+		{`if ({}["x"] == null) { 10 }`, 10},
+		{"if ([1][0] != null) { 10 }", 10},
 	}
 
 	for _, tt := range testing {
@@ -225,10 +334,22 @@ func TestErrorHandling(t *testing.T) {
 			`,
 			"unknown operator: BOOLEAN + BOOLEAN",
 		},
+		{
+			"1.5 + true",
+			"type mismatch: FLOAT + BOOLEAN",
+		},
+		{
+			`1.5 + "a"`,
+			"type mismatch: FLOAT + STRING",
+		},
 		{
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			`import "lib/strings"; strings`,
+			`cannot import "lib/strings": imports are disabled`,
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -237,6 +358,18 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"let f = fn(a, b) { a }; f(1)",
+			"wrong number of arguments: want=2, got=1",
+		},
+		{
+			"quote()",
+			"wrong number of arguments: want=1, got=0",
+		},
+		{
+			"quote(1, 2)",
+			"wrong number of arguments: want=1, got=2",
+		},
 	}
 
 	for _, tt := range tests {
@@ -254,6 +387,98 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { throw "boom" } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { e }`, "Error: boom"},
+		{`try { throw {"a": 1} } catch (e) { [e["kind"], e["message"], e["value"]["a"]] }`, "[thrown, {a: 1}, 1]"},
+		{`try { 1 / 0 } catch (e) { [e["kind"], e["message"], e["position"], e["value"]] }`,
+			"[arithmetic, division by zero, 1:7, null]"},
+		{`try { len(1, 2) } catch (e) { [e["kind"], e["message"]] }`,
+			"[argument, wrong number of arguments: want=1, got=2]"},
+		{`try { missing } catch (e) { e["kind"] }`, "name"},
+		{`try { [1]["a"] = 1 } catch (e) { e["kind"] }`, "type"},
+		{`try { throw "a" } catch (e) { e["stack"] }`, "Error: exception has no field stack"},
+		{`try { throw "a" } catch (e) { 1 }; e`, "Error: identifier not found: e"},
+		{`let e = 9; try { throw "a" } catch (e) { let y = e; 1 }; [e, try { y } catch (e) { e["message"] }]`, "[9, identifier not found: y]"},
+		{`try { throw "a" } catch (e) { throw e["message"] + "b" }`, "Error: ab"},
+		{`try { throw "a" } catch (e) { throw e }`, "Error: a"},
+		{`try { throw "a" } finally { 1 }`, "Error: a"},
+		{`try { 1 } finally { 2 }`, "1"},
+		{`try { let x = 1; } finally { 2 }`, "null"},
+		{`try { 1 } finally { throw "f" }`, "Error: f"},
+		{`try { throw "a" } catch (e) { 1 } finally { throw "c" }`, "Error: c"},
+		{`let log = [0]; let r = try { 1 / 0 } catch (e) { log = push(log, 1); 2 } finally { log = push(log, 3) }; [r, log]`,
+			"[2, [0, 1, 3]]"},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, "2"},
+		{`let n = 0; let f = fn() { try { return n } finally { n += 1 } }; [f(), f(), n]`, "[0, 1, 2]"},
+		{`let f = fn() { try { throw "x" } catch (e) { return 1 } finally { return 2 } }; f()`, "2"},
+		{`let f = fn(g) { try { g() } catch (e) { e["message"] } }; [f(fn() { 1 }), f(fn() { throw "inner" })]`,
+			"[1, inner]"},
+		{`let xs = [0]; for (i in [1, 2, 3, 4]) { try { if (i == 2) { continue } if (i == 4) { break } xs = push(xs, i) } finally { xs = push(xs, -i) } }; xs`,
+			"[0, 1, -1, -2, 3, -3, -4]"},
+		{`let f = fn() { for (x in [1, 2]) { try { return x } finally { break } } 9 }; f()`, "9"},
+		{`let f = fn() { f() }; try { f() } catch (e) { 1 }`, "Error: call depth limit of 10000 exceeded"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`match (1) { 0 => "zero", 1 => "one", _ => "many" }`, "one"},
+		{`match (7) { 0 => "zero", 1 => "one", _ => "many" }`, "many"},
+		{`match (-2) { -2 => "minus two", _ => "other" }`, "minus two"},
+		{`match (1.0) { 1 => "int", 1.0 => "float" }`, "float"},
+		{`match ("b") { "a" => 1, "b" => 2 }`, "2"},
+		{`match (null) { false => 1, null => 2 }`, "2"},
+		{`match ([1, 2]) { [] => "empty", [x] => "one", [x, y] => x + y }`, "3"},
+		{`match ([1, 2, 3]) { [head, ...tail] => [head, tail] }`, "[1, [2, 3]]"},
+		{`match ([1]) { [head, ...tail] => [head, tail] }`, "[1, []]"},
+		{`match ([]) { [head, ...tail] => head, _ => "empty" }`, "empty"},
+		{`match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }`, "6"},
+		{`match ([1, 2]) { [_, _, ..._] => "two or more" }`, "two or more"},
+		{`match ({"name": "Ada", "age": 36}) { {"name": n, "age": 36} => n }`, "Ada"},
+		{`match ({"name": "Ada", "age": 36}) { {name, age} => [name, age] }`, "[Ada, 36]"},
+		{`match ({1: "x", true: [2]}) { {1: a, true: [b]} => [a, b] }`, "[x, 2]"},
+		{`match ({"a": 1}) { {"b": b} => b, {} => "any hash" }`, "any hash"},
+		{`match ([1, 2]) { {} => "hash", _ => "not a hash" }`, "not a hash"},
+		{`match (5) { n if n < 0 => "negative", n if n > 0 => "positive", _ => "zero" }`, "positive"},
+		{`match ([3, 1]) { [a, b] if a < b => "sorted", [a, b] => [b, a] }`, "[1, 3]"},
+		{`let x = 1; match (2) { x => x }; x`, "1"},
+		{`let x = 1; match (5) { x if false => 0, _ => 1 }; x`, "1"},
+		{`let x = 1; let f = fn() { x }; match ([7]) { [x] => 0 }; [f(), x]`, "[1, 1]"},
+		{`let x = 1; let set = fn(v) { x = v }; match (5) { y => set(y) }; x`, "5"},
+		{`let f = fn(xs) { match (xs) { [] => 0, [h, ...t] => h + f(t) } }; f([1, 2, 3, 4])`, "10"},
+		{`match (match (1) { 1 => [2] }) { [n] => match (n) { 2 => "two" } }`, "two"},
+		{`match (5) { 1 => 2 }`, "Error: no pattern matches 5"},
+		{`match ([1, 2]) { [x] => x }`, "Error: no pattern matches [1, 2]"},
+		{`match (1) { n if n + true => 1 }`, "Error: type mismatch: INTEGER + BOOLEAN"},
+		{`try { match ("x") { } } catch (e) { e["kind"] }`, "match"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -263,6 +488,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let größe = 5; let 長さ2 = größe * 2; 長さ2;", 10},
 	}
 
 	for _, tt := range tests {
@@ -270,6 +496,198 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]`, "[1, 2, [3, 4]]"},
+		{`let [a, ...rest] = [1]; rest`, "[]"},
+		{`let {"name": n, "age": a} = {"name": "Ann", "age": 7, "x": 0}; [n, a]`, "[Ann, 7]"},
+		{`let {name, age = 30} = {"name": "Bo"}; [name, age]`, "[Bo, 30]"},
+		{`let {tags = []} = {"tags": null}; tags`, "[]"},
+		{`let [x, [y, z = x + y]] = [1, [2]]; [x, y, z]`, "[1, 2, 3]"},
+		{`let [x, y = 5] = [1, 2]; y`, "2"},
+		{`let [_, {"p": [_, q]}] = [0, {"p": [1, 2]}]; q`, "2"},
+		{`let [a, b = a * 2, c = b + 1] = [1]; [a, b, c]`, "[1, 2, 3]"},
+		{`let f = fn([a, b], {k}, c) { a + b + k + c }; f([1, 2], {"k": 3}, 4)`, "10"},
+		{`let f = fn(x, [h, t = x]) { [h, t] }; [f(0, [1]), f(0, [1, 2])]`, "[[1, 0], [1, 2]]"},
+		{`let g = fn() { let [a, b] = [1, 2]; fn() { a + b } }; g()()`, "3"},
+		{`let [a, b] = [1, 2, 3]`, "Error: cannot destructure [1, 2, 3]: expected 2 elements, got 3"},
+		{`let [a, ...b] = []`, "Error: cannot destructure []: expected at least 1 element, got 0"},
+		{`let [a, b = 1] = []`, "Error: cannot destructure []: expected 1 to 2 elements, got 0"},
+		{`let [a] = 5`, "Error: cannot destructure 5: expected an array, got INTEGER"},
+		{`let {name} = {"age": 1}`, "Error: cannot destructure {age: 1}: missing key name"},
+		{`let {"p": {q}} = {"p": [1]}`, "Error: cannot destructure {p: [1]}: expected a hash, got ARRAY"},
+		{`let [0, a] = [1, 2]`, "Error: cannot destructure [1, 2]: expected 0, got 1"},
+		{`let [a, b = missing] = [1]`, "Error: identifier not found: missing"},
+		{`let f = fn([a]) { a }; f(1)`, "Error: cannot destructure 1: expected an array, got INTEGER"},
+		{`try { let {x} = {} } catch (e) { e["kind"] }`, "match"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", "5"},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i", "3"},
+		{"while (false) { 1 }", "null"},
+		{"let s = 0; for (x in [1, 2, 3]) { s = s + x; }; s", "6"},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } s = s + x; }; s", "8"},
+		{`let s = ""; for (c in "héllo") { s = c + s; }; s`, "olléh"},
+		{`let ks = [0]; for (k in {"b": 1, 2: 0, "a": 1, 1.5: 0, true: 0, -1: 0}) { ks = push(ks, k); }; ks`,
+			"[0, true, -1, 1.5, 2, a, b]"},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } n = n + 1; } }; n", "2"},
+		{"let x = 10; for (x in [1, 2]) { x += 1 }; x", "10"},
+		{"for (x in [1]) { let y = 2; }; y", "Error: identifier not found: y"},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10; } } 0 }; f()", "20"},
+		{"let f = fn() { while (true) { return 7; } }; f()", "7"},
+		{"for (x in []) { x }; 1", "1"},
+		{"for (x in 5) { x }", "Error: cannot iterate over INTEGER"},
+		{"for (x in [1, 2]) { x + true }", "Error: type mismatch: INTEGER + BOOLEAN"},
+		{"while (1 + true) { 1 }", "Error: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", "6"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let i = 0; let f = fn() { i = i + 1; }; f(); f(); i", "2"},
+		{"let x = 1; let f = fn() { let x = 5; x = 6; x }; [f(), x]", "[6, 1]"},
+		{`let counter = fn() { let n = 0; [fn() { n += 1; n }, fn() { n }] };
+		  let c = counter(); c[0](); c[0](); c[1]()`, "2"},
+		{"let i = 0; let s = 0; while (i < 4) { i += 1; s += i; }; s", "10"},
+		{"let a = [1, 2, 3]; a[1] = 20; a[2] *= 10; a", "[1, 20, 30]"},
+		{"let a = [1, 2]; let b = a; b[0] = 5; a", "[5, 2]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] += 10; [h["a"], h["b"]]`, "[11, 2]"},
+		{"let m = [[1], [2]]; m[1][0] = 7; m", "[[1], [7]]"},
+		{"let f = fn() { let y = 1; y = 2; }; f()", "null"},
+		{"let x = 0; let r = if (true) { x = 2 }; [r, x]", "[null, 2]"},
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{`let h = {"k": 1}; h["k"] = [h]; "${h}"`, `{k: [{...}]}`},
+		{"let a = [1]; let b = [a, a]; a[0] = b; b", "[[[...]], [[...]]]"},
+		{"x = 1", "Error: identifier not found: x"},
+		{"len = 1", "Error: identifier not found: len"},
+		{"let x = 1; x += true", "Error: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "Error: index 1 out of range for array of length 1"},
+		{"let a = [1]; a[-1] = 2", "Error: index -1 out of range for array of length 1"},
+		{`let a = [1]; a["x"] = 2`, "Error: array index must be INTEGER, got STRING"},
+		{`let s = "abc"; s[0] = "x"`, "Error: index assignment not supported: STRING"},
+		{"let h = {}; h[fn() { 1 }] = 2", "Error: unusable as hash key: FUNCTION"},
+		{"let h = {}; h[1] += 2", "Error: type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true && true", "true"},
+		{"true && false", "false"},
+		{"false || true", "true"},
+		{"false || false", "false"},
+		{"1 && \"a\"", "true"},
+		{"0 || false", "true"},
+		{"false && missing", "false"},
+		{"true || missing", "true"},
+		{"true && missing", "Error: identifier not found: missing"},
+		{"let n = 0; let bump = fn() { n += 1; true }; false && bump(); true || bump(); n", "0"},
+		{"let x = 5; x > 1 && x < 10", "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestArithmeticAndBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7.5 % 2", "1.5"},
+		{"2 ** 10", "1024"},
+		{"2 ** 64", "18446744073709551616"},
+		{"(-3) ** 3", "-27"},
+		{"-2 ** 2", "-4"},
+		{"2 ** 3 ** 2", "512"},
+		{"2 ** 0.5 == 1.4142135623730951", "true"},
+		{"(-1) ** 99999999999999999999", "-1"},
+		{"(-1) ** 100000000000000000000", "1"},
+		{"2 ** -1", "Error: negative exponent: -1"},
+		{"2 ** 10000000", "Error: integer result too large: 2 ** 10000000"},
+		{"3 <= 3", "true"},
+		{"3 >= 4", "false"},
+		{"1.5 >= 1", "true"},
+		{"99999999999999999999 >= 99999999999999999999", "true"},
+		{"12 & 10", "8"},
+		{"12 | 10", "14"},
+		{"12 ^ 10", "6"},
+		{"-1 & 255", "255"},
+		{"1 << 62", "4611686018427387904"},
+		{"1 << 64", "18446744073709551616"},
+		{"-16 >> 2", "-4"},
+		{"5 >> 100", "0"},
+		{"(1 << 70) >> 69", "2"},
+		{"(1 << 70) | 1", "1180591620717411303425"},
+		{"1 << -1", "Error: negative shift count: -1"},
+		{"1 << 99999999999999999999", "Error: integer result too large: 1 << 99999999999999999999"},
+		{"1.5 & 1", "Error: unknown operator: FLOAT & INTEGER"},
+		{"1 / 0", "Error: division by zero"},
+		{"1 % 0", "Error: division by zero"},
+		{"1.0 / 0", "Error: division by zero"},
+		{"1 % 0.0", "Error: division by zero"},
+		{"99999999999999999999 / 0", "Error: division by zero"},
+		{"99999999999999999999 % 7", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
@@ -313,6 +731,37 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(x, y = 10) { [x, y] }; [f(1), f(1, 2), f(1, null)]", "[[1, 10], [1, 2], [1, 10]]"},
+		{"let f = fn(x, y = x * 2, z = x + y) { [x, y, z] }; f(1)", "[1, 2, 3]"},
+		{"let f = fn(first, ...rest) { [first, rest] }; [f(1), f(1, 2, 3)]", "[[1, []], [1, [2, 3]]]"},
+		{"let f = fn(...xs) { xs }; f()", "[]"},
+		{"let f = fn([a, b], n = a + b, ...more) { [n, more] }; f([1, 2], null, 3)", "[3, [3]]"},
+		{"let add = fn(x, y) { x + y }; add(...[1, 2])", "3"},
+		{"let f = fn(...xs) { xs }; f(0, ...[1, 2], 3, ...[])", "[0, 1, 2, 3]"},
+		{`len(...["abc"])`, "3"},
+		{"let f = fn(x) { x }; f(1, 2)", "Error: wrong number of arguments: want=1, got=2"},
+		{"let f = fn(x, y = 1) { x }; f()", "Error: wrong number of arguments: want=1 to 2, got=0"},
+		{"let f = fn(x, y = 1) { x }; f(1, 2, 3)", "Error: wrong number of arguments: want=1 to 2, got=3"},
+		{"let f = fn(x, ...r) { x }; f()", "Error: wrong number of arguments: want=at least 1, got=0"},
+		{"let f = fn(x) { x }; f(...[1, 2])", "Error: wrong number of arguments: want=1, got=2"},
+		{"let f = fn(x) { x }; f(...1)", "Error: spread argument must be ARRAY, got INTEGER"},
+		{"let f = fn(x = missing) { x }; f()", "Error: identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%v",
+				tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 		let newAdder = fn(x) {
@@ -340,6 +789,75 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapesAndIndexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a\tb\n"`, "a\tb\n"},
+		{"`C:\\dir\\n`", `C:\dir\n`},
+		{`len("héllo")`, 5},
+		{`len("\u{1F600}")`, 1},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[4]`, "o"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-1]`, nil},
+		{`""[0]`, nil},
+		{`let s = "日本語"; s[len(s) - 1]`, "語"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("%s: String has wrong value. want=%q, got=%q", tt.input, expected, str.Value)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ann"; "hello ${name}!"`, "hello Ann!"},
+		{`let items = [1, 2]; "${len(items)} items: ${items}"`, "2 items: [1, 2]"},
+		{`"${1 + 1}${true}${1.5}"`, "2true1.5"},
+		{`"outer ${"inner ${1 * 2}"}"`, "outer inner 2"},
+		{`"no ${"interpolation"} in \${here}"`, "no interpolation in ${here}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("%s: String has wrong value. want=%q, got=%q", tt.input, tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(`"value: ${1 + true}"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("error not propagated. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -363,7 +881,7 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+		{`len("one", "two")`, "wrong number of arguments: want=1, got=2"},
 	}
 
 	for _, tt := range tests {
@@ -537,6 +1055,14 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{0.5: 5}[1 / 2.0]`,
+			5,
+		},
+		{
+			`{1.0: 5}[1]`,
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -664,3 +1190,49 @@ func TestQuoteUnquote(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorPositionsAndStack(t *testing.T) {
+	input := `let inner = fn(a) {
+  a + y
+};
+let outer = fn(b) {
+  inner(b * 2)
+};
+let result = outer(1);`
+
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if pos := errObj.Span.Start; pos.Line != 2 || pos.Column != 7 {
+		t.Errorf("wrong error position. got=%s", pos)
+	}
+
+	expected := []struct {
+		function string
+		line     int
+		column   int
+	}{
+		{"inner", 5, 3},
+		{"outer", 7, 14},
+	}
+
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong number of frames. want=%d, got=%d",
+			len(expected), len(errObj.Stack))
+	}
+
+	for i, frame := range errObj.Stack {
+		call := frame.Call.Start
+		if frame.Function != expected[i].function {
+			t.Errorf("frames[%d] wrong function. want=%q, got=%q",
+				i, expected[i].function, frame.Function)
+		}
+		if call.Line != expected[i].line || call.Column != expected[i].column {
+			t.Errorf("frames[%d] wrong call site. want=%d:%d, got=%s",
+				i, expected[i].line, expected[i].column, call)
+		}
+	}
+}
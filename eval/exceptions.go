@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	return throw(val)
+}
+
+// throw returns the error a throw statement raises with value. Throwing a
+// caught exception raises its error again, with the position and calls of
+// the original.
+func throw(value object.Object) *object.Error {
+	if exception, ok := value.(*object.Exception); ok {
+		err := *exception.Err
+		err.Stack = append([]object.Frame(nil), err.Stack...)
+		return &err
+	}
+
+	message := value.Inspect()
+	if str, ok := value.(*object.String); ok {
+		message = str.Value
+	}
+
+	return &object.Error{Message: message, Kind: object.ThrownError, Value: value}
+}
+
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+
+	if err, ok := result.(*object.Error); ok && isCatchable(err) && node.Catch != nil {
+		// The parameter, like the names the catch block binds, is only
+		// visible in the catch block.
+		catchEnv := object.NewEnclosedEnvironment(env)
+		catchEnv.Set(node.Param.Value, &object.Exception{Err: err})
+		result = Eval(node.Catch, catchEnv)
+	}
+
+	if node.Finally != nil {
+		if err, ok := result.(*object.Error); ok && !isCatchable(err) {
+			return err
+		}
+
+		// The finally block only changes the result when it does not
+		// complete normally, by failing, returning or leaving a loop.
+		final := Eval(node.Finally, env)
+		if final != nil {
+			switch final.Type() {
+			case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return final
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// isCatchable reports whether a try expression may handle err. Errors that
+// stopped an evaluation exceeding its budget must reach the embedder.
+func isCatchable(err *object.Error) bool {
+	return err.Limit == ""
+}
+
+func evalExceptionIndexExpression(exception, index object.Object) object.Object {
+	err := exception.(*object.Exception).Err
+
+	if name, ok := index.(*object.String); ok {
+		switch name.Value {
+		case "message":
+			return &object.String{Value: err.Message}
+		case "kind":
+			return &object.String{Value: string(err.Kind)}
+		case "position":
+			return &object.String{Value: err.Span.Start.String()}
+		case "value":
+			if err.Value == nil {
+				return NULL
+			}
+			return err.Value
+		}
+	}
+
+	return newError(object.NameError, "exception has no field %s", index.Inspect())
+}
@@ -0,0 +1,127 @@
+package eval
+
+import (
+	"math/big"
+	"monkey/object"
+	"monkey/token"
+)
+
+// evalBigIntegerInfixExpression applies operator to two integers of which
+// at least one is big or whose result overflows an int64.
+func evalBigIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case token.PLUS:
+		return newInteger(new(big.Int).Add(leftVal, rightVal))
+	case token.MINUS:
+		return newInteger(new(big.Int).Sub(leftVal, rightVal))
+	case token.ASTERISK:
+		return newInteger(new(big.Int).Mul(leftVal, rightVal))
+	case token.SLASH:
+		if rightVal.Sign() == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		// Quo and Rem truncate towards zero like int64 division.
+		return newInteger(new(big.Int).Quo(leftVal, rightVal))
+	case token.PERCENT:
+		if rightVal.Sign() == 0 {
+			return newError(object.ArithmeticError, "division by zero")
+		}
+		return newInteger(new(big.Int).Rem(leftVal, rightVal))
+	case token.AMPERSAND:
+		return newInteger(new(big.Int).And(leftVal, rightVal))
+	case token.PIPE:
+		return newInteger(new(big.Int).Or(leftVal, rightVal))
+	case token.CARET:
+		return newInteger(new(big.Int).Xor(leftVal, rightVal))
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT:
+		return evalShift(operator, leftVal, rightVal)
+	case token.POWER:
+		return evalPower(leftVal, rightVal)
+	case token.EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case token.NEQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	case token.GT:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case token.LT:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	default:
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// maxIntegerBits bounds the results of << and **, which can be far too
+// large to compute even when both operands are small.
+const maxIntegerBits = 1 << 20
+
+func evalShift(operator string, value, count *big.Int) object.Object {
+	if count.Sign() < 0 {
+		return newError(object.ArithmeticError, "negative shift count: %s", count)
+	}
+
+	if operator == token.SHIFT_RIGHT {
+		// Shifting by at least the length of value leaves its sign.
+		if !count.IsInt64() || count.Int64() > int64(value.BitLen()) {
+			count = big.NewInt(int64(value.BitLen()))
+		}
+		return newInteger(new(big.Int).Rsh(value, uint(count.Int64())))
+	}
+
+	if value.Sign() == 0 {
+		return newInteger(value)
+	}
+	if !count.IsInt64() || count.Int64() > maxIntegerBits-int64(value.BitLen()) {
+		return newError(object.ArithmeticError, "integer result too large: %s << %s", value, count)
+	}
+	return newInteger(new(big.Int).Lsh(value, uint(count.Int64())))
+}
+
+func evalPower(base, exponent *big.Int) object.Object {
+	if exponent.Sign() < 0 {
+		return newError(object.ArithmeticError, "negative exponent: %s", exponent)
+	}
+
+	// 0, 1 and -1 stay small whatever the exponent.
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		if base.Sign() < 0 && exponent.Bit(0) == 0 {
+			return newInteger(big.NewInt(1))
+		}
+		if base.Sign() == 0 && exponent.Sign() == 0 {
+			return newInteger(big.NewInt(1))
+		}
+		return newInteger(base)
+	}
+
+	if !exponent.IsInt64() || exponent.Int64() > maxIntegerBits ||
+		int64(base.BitLen()-1)*exponent.Int64() > maxIntegerBits {
+		return newError(object.ArithmeticError, "integer result too large: %s ** %s", base, exponent)
+	}
+	return newInteger(new(big.Int).Exp(base, exponent, nil))
+}
+
+func evalBigIntegerNegation(right object.Object) object.Object {
+	return newInteger(new(big.Int).Neg(toBigInt(right)))
+}
+
+// newInteger returns value as an Integer if it fits in an int64 and as a
+// BigInteger otherwise.
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInteger{Value: value}
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	if integer, ok := obj.(*object.Integer); ok {
+		return big.NewInt(integer.Value)
+	}
+	return obj.(*object.BigInteger).Value
+}
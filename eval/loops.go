@@ -0,0 +1,140 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"sort"
+)
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		if result, done := loopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	items := iterate(iterable)
+	if isError(items) {
+		return items
+	}
+
+	// Strings and hashes are visited through a new array, which for a
+	// string also holds a new string per character.
+	if items != iterable {
+		size := object.SizeOf(items)
+		if _, ok := iterable.(*object.String); ok {
+			for _, ch := range items.(*object.Array).Elements {
+				size += object.SizeOf(ch)
+			}
+		}
+
+		if err := charge(env, size); err != nil {
+			return err
+		}
+	}
+
+	// The variable, like the names the body binds, is only visible in the
+	// loop.
+	loopEnv := object.NewEnclosedEnvironment(env)
+	for _, item := range items.(*object.Array).Elements {
+		loopEnv.Set(fs.Variable.Value, item)
+
+		if result, done := loopBody(fs.Body, loopEnv); done {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// loopBody runs one iteration of a loop. done is set when the loop must
+// stop, in which case result is the value of the loop: NULL after a break,
+// or the return value or error that ends it.
+func loopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, done bool) {
+	result = Eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return NULL, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	default:
+		return nil, false
+	}
+}
+
+// iterate returns the values a for loop over obj visits, as an array: the
+// elements of an array, the characters of a string or the keys of a hash.
+// Hash keys are visited in order, booleans first, then numbers and then
+// strings, so loops over hashes are deterministic.
+func iterate(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj
+	case *object.String:
+		elements := []object.Object{}
+		for _, ch := range obj.Value {
+			elements = append(elements, &object.String{Value: string(ch)})
+		}
+		return &object.Array{Elements: elements}
+	case *object.Hash:
+		keys := make([]object.Object, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			keys = append(keys, pair.Key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keyLess(keys[i], keys[j])
+		})
+		return &object.Array{Elements: keys}
+	default:
+		return newError(object.TypeError, "cannot iterate over %s", obj.Type())
+	}
+}
+
+func keyLess(a, b object.Object) bool {
+	if rankA, rankB := keyRank(a), keyRank(b); rankA != rankB {
+		return rankA < rankB
+	}
+
+	switch a := a.(type) {
+	case *object.Boolean:
+		return !a.Value && b.(*object.Boolean).Value
+	case *object.String:
+		return a.Value < b.(*object.String).Value
+	}
+
+	if a.Type() == object.INTEGER_OBJ && b.Type() == object.INTEGER_OBJ {
+		return toBigInt(a).Cmp(toBigInt(b)) < 0
+	}
+	return toFloat(a) < toFloat(b)
+}
+
+func keyRank(key object.Object) int {
+	switch key.(type) {
+	case *object.Boolean:
+		return 0
+	case *object.String:
+		return 2
+	default:
+		return 1
+	}
+}
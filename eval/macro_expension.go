@@ -1,14 +1,23 @@
 package eval
 
 import (
+	"fmt"
 	"monkey/ast"
 	"monkey/object"
 )
 
+// DefineMacros moves the macro definitions of program into env, and binds
+// the modules program imports in env to the macros they export, so calls
+// such as mod["name"](x) can be expanded.
 func DefineMacros(program *ast.Program, env *object.Environment) {
 	definitions := []int{}
 
 	for i, stmt := range program.Statements {
+		if imp, ok := stmt.(*ast.ImportStatement); ok {
+			importMacros(imp, env)
+			continue
+		}
+
 		if isMacroDefinition(stmt) {
 			addMacro(stmt, env)
 			definitions = append(definitions, i)
@@ -36,36 +45,87 @@ func ExpandMacros(program *ast.Program, env *object.Environment) ast.Node {
 			return node
 		}
 
+		// A call that does not fit the macro is left to fail when it is
+		// run, as a call of a function would.
+		min, max := ast.Arity(macro.Parameters, macro.Rest)
+		if err := checkArity(min, max, len(callExpr.Arguments)); err != nil {
+			return &ast.ExpansionError{Call: callExpr, Kind: string(err.Kind), Message: err.Message}
+		}
+
 		args := quoteArgs(callExpr)
-		evalEnv := extendMacroEnv(macro, args)
+		evalEnv, err := extendMacroEnv(macro, args)
+		if err != nil {
+			return &ast.ExpansionError{Call: callExpr, Kind: string(err.Kind), Message: err.Message}
+		}
 
-		evaluated := Eval(macro.Body, evalEnv)
-		quote, ok := evaluated.(*object.Quote)
-		if !ok {
-			panic("We only support returning AST-Nodes from macros")
+		evaluated := unwrapReturnValue(Eval(macro.Body, evalEnv))
+		if evaluated == nil {
+			evaluated = NULL
 		}
 
-		return quote.Node
+		switch evaluated := evaluated.(type) {
+		case *object.Quote:
+			return evaluated.Node
+		case *object.Error:
+			return &ast.ExpansionError{Call: callExpr, Kind: string(evaluated.Kind),
+				Message: evaluated.Message, Limit: string(evaluated.Limit)}
+		default:
+			return &ast.ExpansionError{Call: callExpr, Kind: string(object.TypeError),
+				Message: fmt.Sprintf("macro must return a quote, got %s", evaluated.Type())}
+		}
 	})
 }
 
+// importMacros binds the macros exported by the module imp imports. A module
+// that fails to load is skipped here; evaluating the import reports why.
+func importMacros(imp *ast.ImportStatement, env *object.Environment) {
+	importer := env.Importer()
+	if importer == nil {
+		return
+	}
+
+	if module, ok := importer.ImportMacros(imp.Path.Value, imp.Span()).(*object.Module); ok {
+		env.Set(imp.Name.Value, module)
+	}
+}
+
 func isMacroCall(expr *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
-	ident, ok := expr.Function.(*ast.Identifier)
+	var obj object.Object
+
+	switch function := expr.Function.(type) {
+	case *ast.Identifier:
+		obj, _ = env.Get(function.Value)
+	case *ast.IndexExpression:
+		obj = lookupModuleMacro(function, env)
+	}
+
+	macro, ok := obj.(*object.Macro)
 	if !ok {
 		return nil, false
 	}
 
-	obj, ok := env.Get(ident.Value)
+	return macro, true
+}
+
+// lookupModuleMacro returns the export of an imported module that
+// mod["name"] refers to, or nil.
+func lookupModuleMacro(expr *ast.IndexExpression, env *object.Environment) object.Object {
+	ident, ok := expr.Left.(*ast.Identifier)
 	if !ok {
-		return nil, false
+		return nil
+	}
+	name, ok := expr.Index.(*ast.StringLiteral)
+	if !ok {
+		return nil
 	}
 
-	macro, ok := obj.(*object.Macro)
+	obj, _ := env.Get(ident.Value)
+	module, ok := obj.(*object.Module)
 	if !ok {
-		return nil, false
+		return nil
 	}
 
-	return macro, true
+	return module.Exports[name.Value]
 }
 
 func quoteArgs(expr *ast.CallExpression) []*object.Quote {
@@ -78,19 +138,48 @@ func quoteArgs(expr *ast.CallExpression) []*object.Quote {
 	return args
 }
 
-func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+// extendMacroEnv binds the parameters of macro to the quoted args. A
+// parameter without an argument gets the value of its default, which may
+// be a quote or any value unquote accepts.
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) (*object.Environment, *object.Error) {
 	extendedEnv := object.NewEnclosedEnvironment(macro.Env)
 
-	for idx, arg := range macro.Parameters {
-		extendedEnv.Set(arg.Value, args[idx])
+	var defaults []*ast.DefaultPattern
+	for idx, param := range macro.Parameters {
+		arg := object.Object(NULL)
+		if idx < len(args) {
+			arg = args[idx]
+		}
+
+		switch param := param.(type) {
+		case *ast.Identifier:
+			extendedEnv.Set(param.Value, arg)
+		case *ast.DefaultPattern:
+			extendedEnv.Set(param.Name.Value, arg)
+			defaults = append(defaults, param)
+		}
+	}
+
+	if macro.Rest != nil {
+		rest := []object.Object{}
+		if n := len(macro.Parameters); len(args) > n {
+			for _, arg := range args[n:] {
+				rest = append(rest, arg)
+			}
+		}
+		extendedEnv.Set(macro.Rest.Value, &object.Array{Elements: rest})
+	}
+
+	if err := bindDefaults(defaults, extendedEnv); err != nil {
+		return nil, err.(*object.Error)
 	}
 
-	return extendedEnv
+	return extendedEnv, nil
 }
 
 func isMacroDefinition(stmt ast.Statement) bool {
-	letStatement, ok := stmt.(*ast.LetStatement)
-	if !ok {
+	letStatement, ok := definition(stmt)
+	if !ok || letStatement.Name == nil {
 		return false
 	}
 
@@ -98,12 +187,23 @@ func isMacroDefinition(stmt ast.Statement) bool {
 	return ok
 }
 
+// definition returns the let statement stmt is, or exports.
+func definition(stmt ast.Statement) (*ast.LetStatement, bool) {
+	if export, ok := stmt.(*ast.ExportStatement); ok {
+		return export.Statement, true
+	}
+
+	letStatement, ok := stmt.(*ast.LetStatement)
+	return letStatement, ok
+}
+
 func addMacro(stmt ast.Statement, env *object.Environment) {
-	letStatement, _ := stmt.(*ast.LetStatement)
+	letStatement, _ := definition(stmt)
 	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
 
 	macro := &object.Macro{
 		Parameters: macroLiteral.Parameters,
+		Rest:       macroLiteral.Rest,
 		Body:       macroLiteral.Body,
 		Env:        env,
 	}
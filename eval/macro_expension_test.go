@@ -100,6 +100,51 @@ func TestExpandMacros(t *testing.T) {
 			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }
 			if (!(10 < 5)) { puts("not greater") } else { puts("greater") }`,
 		},
+		{
+			`
+            let greet = macro(who) { quote("hi ${unquote(who)}, ${1 + 1} times"); };
+
+            greet(name);
+            `,
+			`"hi ${name}, ${1 + 1} times"`,
+		},
+		{
+			`
+            let firstPositive = macro(xs, fallback) {
+                quote(match (unquote(xs)) { [x, ..._] if x > 0 => x, _ => unquote(fallback) });
+            };
+
+            firstPositive(items, 0 - 1);
+            `,
+			`match (items) { [x, ..._] if x > 0 => x, _ => 0 - 1 }`,
+		},
+		{
+			`
+            let second = macro(first, ...rest) { rest[0] };
+
+            second(1, 2 + 3, 4);
+            second();
+            `,
+			`(2 + 3); second()`,
+		},
+		{
+			`
+            let count = macro(...xs) { quote(len(unquote(xs))) };
+
+            count(1, a, b + c);
+            count();
+            `,
+			`len([1, a, (b + c)]); len([])`,
+		},
+		{
+			`
+            let inc = macro(x, by = 1, label = quote(x)) { quote([unquote(x) + unquote(by), unquote(label)]) };
+
+            inc(a);
+            inc(a, 2 * b, "a");
+            `,
+			`[(a + 1), x]; [(a + (2 * b)), "a"]`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -122,3 +167,33 @@ func testParseProgram(input string) *ast.Program {
 	p := parser.New(l)
 	return p.ParseProgram()
 }
+
+func TestExpandMacrosKeepsPositions(t *testing.T) {
+	input := `let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+reverse(2 + 2, 10 - 5);
+let double = macro(x) { quote(unquote(x) * 2); };
+double(3);`
+
+	program := testParseProgram(input)
+	env := object.NewEnvironment()
+	DefineMacros(program, env)
+	expanded := ExpandMacros(program, env).(*ast.Program)
+
+	stmt := expanded.Statements[0].(*ast.ExpressionStatement)
+	infix := stmt.Expression.(*ast.InfixExpression)
+
+	right := infix.Right.Span().Start
+	if right.Line != 2 || right.Column != 9 {
+		t.Errorf("argument position not kept. got=%d:%d", right.Line, right.Column)
+	}
+
+	stmt = expanded.Statements[1].(*ast.ExpressionStatement)
+	infix = stmt.Expression.(*ast.InfixExpression)
+
+	// The 3 comes from an unquoted argument, so it is a reference to the
+	// original node rather than a converted object.
+	left := infix.Left.Span().Start
+	if left.Line != 4 || left.Column != 8 {
+		t.Errorf("argument position not kept. got=%d:%d", left.Line, left.Column)
+	}
+}
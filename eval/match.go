@@ -0,0 +1,303 @@
+package eval
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		values, ok := matchPattern(arm.Pattern, subject, nil)
+		if !ok {
+			continue
+		}
+
+		// The names an arm binds are only visible in its guard and body.
+		armEnv := object.NewEnclosedEnvironment(env)
+		if err := bindValues(arm.Pattern, values, armEnv); err != nil {
+			return err
+		}
+
+		if arm.Guard != nil {
+			guard := Eval(arm.Guard, armEnv)
+			if isError(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
+	}
+
+	return noMatch(subject)
+}
+
+func noMatch(value object.Object) object.Object {
+	return newError(object.MatchError, "no pattern matches %s", value.Inspect())
+}
+
+// bindPattern destructures value with pattern, as let and function
+// parameters do, and binds the names of the pattern in env.
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment) object.Object {
+	values, err := destructure(pattern, value)
+	if err != nil {
+		return err
+	}
+
+	return bindValues(pattern, values, env)
+}
+
+// bindValues binds the names of pattern to the values it matched and then
+// evaluates the defaults of the names that are bound to null.
+func bindValues(pattern ast.Pattern, values []object.Object, env *object.Environment) object.Object {
+	if err := bindNames(pattern, values, env); err != nil {
+		return err
+	}
+	return bindDefaults(ast.Defaults(pattern), env)
+}
+
+// bindNames binds the names of pattern to the values it matched. The
+// arrays matchPattern built for rest elements are charged to the budget of
+// env here.
+func bindNames(pattern ast.Pattern, values []object.Object, env *object.Environment) object.Object {
+	rests := restNames(pattern, map[*ast.Identifier]bool{})
+
+	for i, name := range ast.Bindings(pattern) {
+		if rests[name] {
+			if err := allocate(env, values[i]); isError(err) {
+				return err
+			}
+		}
+		env.Set(name.Value, values[i])
+	}
+
+	return nil
+}
+
+// restNames adds the names collecting the rest of the array patterns in
+// pattern to names and returns it.
+func restNames(pattern ast.Pattern, names map[*ast.Identifier]bool) map[*ast.Identifier]bool {
+	switch pattern := pattern.(type) {
+	case *ast.ArrayPattern:
+		for _, el := range pattern.Elements {
+			restNames(el, names)
+		}
+		if pattern.Rest != nil {
+			names[pattern.Rest] = true
+		}
+	case *ast.HashPattern:
+		for _, value := range pattern.Values {
+			restNames(value, names)
+		}
+	}
+
+	return names
+}
+
+// bindDefaults evaluates the defaults of the elements whose names are
+// bound to null, in order, and binds them instead.
+func bindDefaults(defaults []*ast.DefaultPattern, env *object.Environment) object.Object {
+	for _, element := range defaults {
+		if value, _ := env.Get(element.Name.Value); value != NULL {
+			continue
+		}
+
+		value := Eval(element.Default, env)
+		if isError(value) {
+			return value
+		}
+		env.Set(element.Name.Value, value)
+	}
+
+	return nil
+}
+
+// destructure returns the values of the names pattern binds in value, or
+// an error explaining why value does not have the shape of pattern.
+func destructure(pattern ast.Pattern, value object.Object) ([]object.Object, *object.Error) {
+	values, ok := matchPattern(pattern, value, nil)
+	if !ok {
+		return nil, newError(object.MatchError, "cannot destructure %s: %s",
+			value.Inspect(), mismatch(pattern, value)).(*object.Error)
+	}
+
+	return values, nil
+}
+
+// matchPattern tests value against pattern. If it matches, it returns
+// bound followed by the values of the names the pattern binds.
+func matchPattern(pattern ast.Pattern, value object.Object, bound []object.Object) ([]object.Object, bool) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value == "_" {
+			return bound, true
+		}
+		return append(bound, value), true
+
+	case *ast.DefaultPattern:
+		return append(bound, value), true
+
+	case *ast.LiteralPattern:
+		return bound, equalsLiteral(literalValue(pattern.Value), value)
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok || !fitsArrayPattern(pattern, array) {
+			return nil, false
+		}
+
+		// The elements missing from the array all have defaults, which
+		// are bound to null for now.
+		for i, el := range pattern.Elements {
+			element := object.Object(NULL)
+			if i < len(array.Elements) {
+				element = array.Elements[i]
+			}
+			if bound, ok = matchPattern(el, element, bound); !ok {
+				return nil, false
+			}
+		}
+
+		if pattern.Rest != nil {
+			rest := []object.Object{}
+			if n := len(pattern.Elements); len(array.Elements) > n {
+				rest = make([]object.Object, len(array.Elements)-n)
+				copy(rest, array.Elements[n:])
+			}
+			bound, _ = matchPattern(pattern.Rest, &object.Array{Elements: rest}, bound)
+		}
+		return bound, true
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return nil, false
+		}
+
+		for i, key := range pattern.Keys {
+			element := object.Object(NULL)
+			if pair, ok := hash.Pairs[literalValue(key).(object.Hashable).HashKey()]; ok {
+				element = pair.Value
+			} else if _, ok := pattern.Values[i].(*ast.DefaultPattern); !ok {
+				return nil, false
+			}
+
+			if bound, ok = matchPattern(pattern.Values[i], element, bound); !ok {
+				return nil, false
+			}
+		}
+		return bound, true
+	}
+
+	return nil, false
+}
+
+// fitsArrayPattern reports whether array has a length pattern accepts: as
+// many elements as the pattern, or fewer if the missing ones have
+// defaults, or more if the pattern collects the rest.
+func fitsArrayPattern(pattern *ast.ArrayPattern, array *object.Array) bool {
+	n := len(array.Elements)
+	return n >= ast.Required(pattern.Elements) && (pattern.Rest != nil || n <= len(pattern.Elements))
+}
+
+// mismatch describes the first part of value that does not match pattern.
+func mismatch(pattern ast.Pattern, value object.Object) string {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		return fmt.Sprintf("expected %s, got %s", pattern.String(), value.Inspect())
+
+	case *ast.ArrayPattern:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return fmt.Sprintf("expected an array, got %s", value.Type())
+		}
+
+		if !fitsArrayPattern(pattern, array) {
+			required := ast.Required(pattern.Elements)
+			want := fmt.Sprint(required)
+			switch {
+			case pattern.Rest != nil:
+				want = "at least " + want
+			case required < len(pattern.Elements):
+				want = fmt.Sprintf("%d to %d", required, len(pattern.Elements))
+			}
+
+			noun := "elements"
+			if want == "1" || want == "at least 1" {
+				noun = "element"
+			}
+			return fmt.Sprintf("expected %s %s, got %d", want, noun, len(array.Elements))
+		}
+
+		for i, el := range pattern.Elements {
+			if i < len(array.Elements) {
+				if _, ok := matchPattern(el, array.Elements[i], nil); !ok {
+					return mismatch(el, array.Elements[i])
+				}
+			}
+		}
+
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return fmt.Sprintf("expected a hash, got %s", value.Type())
+		}
+
+		for i, key := range pattern.Keys {
+			pair, ok := hash.Pairs[literalValue(key).(object.Hashable).HashKey()]
+			if !ok {
+				if _, ok := pattern.Values[i].(*ast.DefaultPattern); ok {
+					continue
+				}
+				return fmt.Sprintf("missing key %s", literalValue(key).Inspect())
+			}
+			if _, ok := matchPattern(pattern.Values[i], pair.Value, nil); !ok {
+				return mismatch(pattern.Values[i], pair.Value)
+			}
+		}
+	}
+
+	return fmt.Sprintf("expected %s, got %s", pattern.String(), value.Inspect())
+}
+
+// literalValue returns the value of a literal in a pattern.
+func literalValue(node ast.Expression) object.Object {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.PrefixExpression:
+		return evalPrefixExpression(node.Operator, literalValue(node.Right))
+	default:
+		return NULL
+	}
+}
+
+// equalsLiteral reports whether value equals the value of a literal
+// pattern. Unlike ==, it never fails: values of different types are just
+// not equal, and in particular 1 does not match 1.0.
+func equalsLiteral(literal, value object.Object) bool {
+	l, ok := literal.(object.Hashable)
+	if !ok {
+		return literal == value
+	}
+
+	v, ok := value.(object.Hashable)
+	return ok && l.HashKey() == v.HashKey()
+}
@@ -0,0 +1,36 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := importModule(env.Importer(), node.Path.Value, node.Span())
+	if isError(module) {
+		return module
+	}
+
+	env.Set(node.Name.Value, module)
+	return nil
+}
+
+func importModule(importer object.Importer, path string, at token.Span) object.Object {
+	if importer == nil {
+		return newError(object.ImportError, "cannot import %q: imports are disabled", path)
+	}
+
+	return importer.Import(path, at)
+}
+
+func evalModuleIndexExpression(module, index object.Object) object.Object {
+	name, ok := index.(*object.String)
+	if ok {
+		if value, ok := module.(*object.Module).Exports[name.Value]; ok {
+			return value
+		}
+	}
+
+	return newError(object.NameError, "module %s does not export %s", module.(*object.Module).Name, index.Inspect())
+}
@@ -0,0 +1,124 @@
+package eval
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+)
+
+// The functions in this file expose the evaluator's semantics for values so
+// the bytecode vm produces exactly the same results and errors.
+
+// InfixOperator applies a binary operator such as "+" or "==".
+func InfixOperator(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// PrefixOperator applies a unary operator such as "!" or "-".
+func PrefixOperator(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// IndexOperator evaluates left[index].
+func IndexOperator(left, index object.Object) object.Object {
+	return evalIndexExpession(left, index)
+}
+
+// SetIndexOperator performs left[index] = value. It returns an error, or
+// nil if the assignment succeeded.
+func SetIndexOperator(left, index, value object.Object) *object.Error {
+	if err := setIndex(left, index, value); err != nil {
+		return err.(*object.Error)
+	}
+	return nil
+}
+
+// Interpolate builds the value of an interpolated string from the values
+// of its parts.
+func Interpolate(values []object.Object) object.Object {
+	return interpolate(values)
+}
+
+// Iterate returns the values a for loop over obj visits as an array, or
+// an error if obj cannot be iterated over.
+func Iterate(obj object.Object) object.Object {
+	return iterate(obj)
+}
+
+// Import returns the module at path, imported by the statement at span at,
+// from importer, which may be nil if the program may not import modules.
+func Import(importer object.Importer, path string, at token.Span) object.Object {
+	return importModule(importer, path, at)
+}
+
+// Throw returns the error a throw statement raises with value.
+func Throw(value object.Object) *object.Error {
+	return throw(value)
+}
+
+// Match tests value against pattern. If it matches, it returns the values
+// of the names the pattern binds, in the order of ast.Bindings.
+func Match(pattern ast.Pattern, value object.Object) ([]object.Object, bool) {
+	return matchPattern(pattern, value, nil)
+}
+
+// Destructure returns the values of the names pattern binds in value, in
+// the order of ast.Bindings, or an error if value does not match.
+func Destructure(pattern ast.Pattern, value object.Object) ([]object.Object, *object.Error) {
+	return destructure(pattern, value)
+}
+
+// CheckArity returns an error if got arguments are too few or too many
+// for a function taking at least min and at most max of them, where max
+// is -1 if there is no limit.
+func CheckArity(min, max, got int) *object.Error {
+	return checkArity(min, max, got)
+}
+
+// Spread returns the elements a spread argument with value passes, or an
+// error if value is not an array.
+func Spread(value object.Object) ([]object.Object, *object.Error) {
+	return spread(value)
+}
+
+// NoMatch returns the error of a match expression without an arm for
+// value.
+func NoMatch(value object.Object) *object.Error {
+	return noMatch(value).(*object.Error)
+}
+
+// IsTruthy reports whether obj counts as true in a condition.
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// NewError returns an error object of the given kind with a formatted
+// message.
+func NewError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return newError(kind, format, a...).(*object.Error)
+}
+
+// BuiltinNames returns the names of the builtin functions in a stable
+// order, suitable for numbering them.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// LookupBuiltin returns the builtin function called name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// ObjectToNode converts the value of the unquote call back into an AST
+// node spanning the call.
+func ObjectToNode(obj object.Object, call *ast.CallExpression) ast.Node {
+	return convertObjectToAstNode(obj, call)
+}
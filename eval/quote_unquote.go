@@ -28,7 +28,7 @@ func evalUnquoteCalls(node ast.Node, env *object.Environment) ast.Node {
 		}
 
 		unquoted := Eval(call.Arguments[0], env)
-		return convertObjectToAstNode(unquoted)
+		return convertObjectToAstNode(unquoted, call)
 	})
 }
 
@@ -41,33 +41,81 @@ func isUnquoteCall(node ast.Node) bool {
 	return callExpression.Function.TokenLiteral() == "unquote"
 }
 
-func convertObjectToAstNode(obj object.Object) ast.Node {
+// convertObjectToAstNode turns the value of the unquote call back into a
+// node, which takes the span of the call. A value that has no node, such as
+// a function, is replaced by an expansion error.
+func convertObjectToAstNode(obj object.Object, call *ast.CallExpression) ast.Node {
+	span := call.Span()
+
 	switch obj := obj.(type) {
 	case *object.Integer:
 		t := token.Token{
 			Type:    token.INT,
 			Literal: fmt.Sprintf("%d", obj.Value),
+			Span:    span,
 		}
 		return &ast.IntegerLiteral{
 			Token: t,
 			Value: obj.Value,
 		}
+	case *object.BigInteger:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: obj.Inspect(),
+			Span:    span,
+		}
+		return &ast.IntegerLiteral{
+			Token: t,
+			Big:   obj.Value,
+		}
+	case *object.Float:
+		t := token.Token{
+			Type:    token.FLOAT,
+			Literal: obj.Inspect(),
+			Span:    span,
+		}
+		return &ast.FloatLiteral{
+			Token: t,
+			Value: obj.Value,
+		}
 	case *object.Boolean:
 		var t token.Token
 		if obj.Value {
-			t = token.Token{Type: token.TRUE, Literal: "true"}
+			t = token.Token{Type: token.TRUE, Literal: "true", Span: span}
 		} else {
-			t = token.Token{Type: token.FALSE, Literal: "false"}
+			t = token.Token{Type: token.FALSE, Literal: "false", Span: span}
 		}
 		return &ast.Boolean{Token: t, Value: obj.Value}
 	case *object.String:
 		return &ast.StringLiteral{
-			Token: token.Token{Type: token.STRING, Literal: obj.Value},
+			Token: token.Token{Type: token.STRING, Literal: obj.Value, Span: span},
 			Value: obj.Value,
 		}
+	case *object.Null:
+		return &ast.NullLiteral{Token: token.Token{Type: token.NULL, Literal: "null", Span: span}}
 	case *object.Quote:
 		return obj.Node
+	case *object.Array:
+		elements := []ast.Expression{}
+		for _, element := range obj.Elements {
+			node := convertObjectToAstNode(element, call)
+			if err, ok := node.(*ast.ExpansionError); ok {
+				return err
+			}
+			elements = append(elements, node.(ast.Expression))
+		}
+		return &ast.ArrayLiteral{
+			Token:    token.Token{Type: token.LBRACKET, Literal: "[", Span: span},
+			Elements: elements,
+			Rbracket: token.Token{Type: token.RBRACKET, Literal: "]", Span: span},
+		}
+	case *object.Error:
+		return &ast.ExpansionError{Call: call, Kind: string(obj.Kind), Message: obj.Message}
 	default:
-		return nil
+		return &ast.ExpansionError{
+			Call:    call,
+			Kind:    string(object.TypeError),
+			Message: fmt.Sprintf("cannot unquote %s", obj.Type()),
+		}
 	}
 }
@@ -0,0 +1,84 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"monkey/eval"
+	"monkey/object"
+)
+
+// Call invokes the Monkey function fn, typically one a program returned or
+// stored in a global, with args converted by ToObject. The result is
+// converted back with FromObject. If the function raises an error, Call
+// returns a *RuntimeError.
+func Call(fn object.Object, args ...interface{}) (interface{}, error) {
+	budget, cancel := eval.NewBudget(context.Background(), eval.Limits{})
+	defer cancel()
+
+	result, err := call(fn, args, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromObject(result), nil
+}
+
+// CallAs is like Call but stores the result in the value out points to,
+// converting it like the arguments of registered functions, so a hash can
+// be decoded straight into a struct.
+func CallAs(out interface{}, fn object.Object, args ...interface{}) error {
+	budget, cancel := eval.NewBudget(context.Background(), eval.Limits{})
+	defer cancel()
+
+	result, err := call(fn, args, budget)
+	if err != nil {
+		return err
+	}
+
+	return Decode(result, out)
+}
+
+// CallContext is like Call but enforces the limits of the interpreter,
+// stops when ctx is done and counts towards its Stats.
+func (in *Interpreter) CallContext(ctx context.Context, fn object.Object, args ...interface{}) (interface{}, error) {
+	budget, done := in.newBudget(ctx)
+	defer done()
+
+	result, err := call(fn, args, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromObject(result), nil
+}
+
+func call(fn object.Object, args []interface{}, budget *object.Budget) (object.Object, error) {
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("not a function: %s", typeOf(fn))
+	}
+
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %s", i+1, err)
+		}
+		objects[i] = obj
+	}
+
+	result := eval.ApplyBudget(fn, objects, budget)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+
+	return result, nil
+}
+
+func typeOf(obj object.Object) string {
+	if obj == nil {
+		return "nil"
+	}
+	return string(obj.Type())
+}
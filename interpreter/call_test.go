@@ -0,0 +1,171 @@
+package interpreter
+
+import (
+	"bytes"
+	"monkey/object"
+	"reflect"
+	"testing"
+)
+
+type request struct {
+	Path    string
+	Method  string `monkey:"method"`
+	Retries int
+	Tags    []string
+	secret  string
+	Ignored bool `monkey:"-"`
+}
+
+type verdict struct {
+	Allow  bool
+	Reason string `monkey:"reason"`
+}
+
+func TestCall(t *testing.T) {
+	in := New(nil)
+
+	_, err := in.Eval(`
+let add = fn(a, b) { a + b };
+let describe = fn(req) {
+  [req["Path"], req["method"], req["Retries"] + 1, len(req["Tags"]), req["secret"], req["Ignored"]]
+};
+let rule = fn(req) {
+  if (req["Retries"] < 3) {
+    {"Allow": true, "reason": "within budget"}
+  } else {
+    {"Allow": false, "reason": "too many retries"}
+  }
+};
+let twice = fn(f, x) { f(f(x)) };
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	global := func(name string) object.Object {
+		fn, ok := in.Get(name)
+		if !ok {
+			t.Fatalf("%s is not defined", name)
+		}
+		return fn
+	}
+
+	tests := []struct {
+		fn       string
+		args     []interface{}
+		expected interface{}
+	}{
+		{"add", []interface{}{1, 2}, int64(3)},
+		{"add", []interface{}{"foo", "bar"}, "foobar"},
+		{
+			"describe",
+			[]interface{}{request{Path: "/", Method: "GET", Retries: 2, Tags: []string{"a"}, secret: "s"}},
+			[]interface{}{"/", "GET", int64(3), int64(1), nil, nil},
+		},
+		{
+			"describe",
+			[]interface{}{&request{Path: "/p", Method: "PUT", Tags: []string{}}},
+			[]interface{}{"/p", "PUT", int64(1), int64(0), nil, nil},
+		},
+		{"twice", []interface{}{func(n int) int { return n * 3 }, 2}, int64(18)},
+	}
+
+	for _, tt := range tests {
+		result, err := Call(global(tt.fn), tt.args...)
+		if err != nil {
+			t.Fatalf("%s%v: unexpected error: %s", tt.fn, tt.args, err)
+		}
+
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%s%v: wrong result. want=%#v, got=%#v", tt.fn, tt.args, tt.expected, result)
+		}
+	}
+
+	var v verdict
+	if err := CallAs(&v, global("rule"), request{Retries: 1}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v != (verdict{Allow: true, Reason: "within budget"}) {
+		t.Errorf("wrong verdict. got=%+v", v)
+	}
+
+	result, err := Call(global("rule"), map[string]int{"Retries": 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[interface{}]interface{}{"Allow": false, "reason": "too many retries"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("wrong result. want=%#v, got=%#v", expected, result)
+	}
+
+	cycle, err := in.Eval("let a = [1]; a[0] = a; a")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	elements, ok := FromObject(cycle).([]interface{})
+	if !ok || len(elements) != 1 || elements[0] != cycle {
+		t.Errorf("array inside itself not kept where it repeats. got=%#v", elements)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	in := New(nil)
+
+	if _, err := in.Eval(`let add = fn(a, b) { a + b }; let broken = fn(x) { x + true };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	add, _ := in.Get("add")
+	broken, _ := in.Get("broken")
+	notFn, _ := ToObject(5)
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{second(Call(add, 1)), "wrong number of arguments: want=2, got=1"},
+		{second(Call(add, make(chan int), 1)), "argument 1: cannot convert chan int to a Monkey value"},
+		{second(Call(notFn)), "not a function: INTEGER"},
+		{second(Call(nil)), "not a function: nil"},
+		{second(Call(broken, 1)), "1:52: type mismatch: INTEGER + BOOLEAN"},
+		{CallAs(new(verdict), add, 1, 2), "cannot use INTEGER as interpreter.verdict"},
+		{CallAs(verdict{}, add, 1, 2), "cannot decode into interpreter.verdict: not a non-nil pointer"},
+	}
+
+	for i, tt := range tests {
+		if tt.err == nil {
+			t.Errorf("tests[%d]: expected an error", i)
+			continue
+		}
+		if tt.err.Error() != tt.expected {
+			t.Errorf("tests[%d]: wrong error. want=%q, got=%q", i, tt.expected, tt.err.Error())
+		}
+	}
+
+	_, err := Call(broken, 1)
+	stack := err.(*RuntimeError).Err.Stack
+	if len(stack) != 1 || stack[0].Function != "broken" {
+		t.Errorf("wrong stack. got=%+v", stack)
+	}
+
+	var stderr bytes.Buffer
+	in = New(&Options{Stderr: &stderr})
+	if _, err := in.Eval("let outer = fn(x) {\n  inner(x)\n};\nlet inner = fn(y) { y + true };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	outer, _ := in.Get("outer")
+
+	_, err = Call(outer, 1)
+	in.PrintError(err)
+
+	expected := "Traceback (most recent call last):\n" +
+		"  at 2:3 in outer\n" +
+		"  at 4:21 in inner\n" +
+		"Error: type mismatch: INTEGER + BOOLEAN\n"
+	if stderr.String() != expected {
+		t.Errorf("wrong stderr. want=%q, got=%q", expected, stderr.String())
+	}
+}
+
+func second(_ interface{}, err error) error {
+	return err
+}
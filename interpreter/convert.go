@@ -0,0 +1,537 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"monkey/eval"
+	"monkey/object"
+	"reflect"
+)
+
+var (
+	objectType         = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType          = reflect.TypeOf((*error)(nil)).Elem()
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+	bigIntType         = reflect.TypeOf((*big.Int)(nil))
+)
+
+// Register makes the Go function fn callable from Monkey as name. Arguments
+// are converted to the parameter types of fn, where interface{} receives
+// the value FromObject returns, and the result is converted with ToObject.
+// fn may return nothing, one value, an error, or a value and an error; a
+// non-nil error becomes a Monkey error.
+func (in *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := newBuiltin(name, fn)
+	if err != nil {
+		return err
+	}
+
+	in.builtins.Set(name, builtin)
+	return nil
+}
+
+func newBuiltin(name string, fn interface{}) (*object.Builtin, error) {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("cannot register %s: %s is not a function", name, fnType)
+	}
+
+	switch fnType.NumOut() {
+	case 0, 1:
+	case 2:
+		if fnType.Out(1) != errorType {
+			return nil, fmt.Errorf("cannot register %s: second result must be error, got %s",
+				name, fnType.Out(1))
+		}
+	default:
+		return nil, fmt.Errorf("cannot register %s: too many results", name)
+	}
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			in, err := convertArguments(fnType, args)
+			if err != nil {
+				return eval.NewError(object.ArgumentError, "%s: %s", name, err)
+			}
+
+			return convertResults(fnValue.Call(in))
+		},
+	}, nil
+}
+
+func convertArguments(fnType reflect.Type, args []object.Object) ([]reflect.Value, error) {
+	numIn := fnType.NumIn()
+
+	min, max := numIn, numIn
+	if fnType.IsVariadic() {
+		min, max = numIn-1, -1
+	}
+	if err := eval.CheckArity(min, max, len(args)); err != nil {
+		return nil, errors.New(err.Message)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var t reflect.Type
+		if fnType.IsVariadic() && i >= numIn-1 {
+			t = fnType.In(numIn - 1).Elem()
+		} else {
+			t = fnType.In(i)
+		}
+
+		value, err := fromObject(arg, t)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %s", i+1, err)
+		}
+		in[i] = value
+	}
+
+	return in, nil
+}
+
+func convertResults(results []reflect.Value) object.Object {
+	if len(results) > 0 {
+		last := results[len(results)-1]
+		if last.Type() == errorType {
+			if !last.IsNil() {
+				return eval.NewError(object.GenericError, "%s", last.Interface().(error))
+			}
+			results = results[:len(results)-1]
+		}
+	}
+
+	if len(results) == 0 {
+		return eval.NULL
+	}
+
+	obj, err := toObject(results[0])
+	if err != nil {
+		return eval.NewError(object.GenericError, "%s", err)
+	}
+
+	return obj
+}
+
+// ToObject converts a Go value to a Monkey object. Booleans, integers,
+// including *big.Int, floats and strings become their Monkey counterparts,
+// slices and arrays become arrays, maps become hashes and nil becomes null. Structs become hashes
+// keyed by field name, which a `monkey:"name"` tag overrides, and Go
+// functions become builtins as with Register. Values that already are
+// objects are returned unchanged. A value that refers to itself, such as a
+// linked list that loops, is an error.
+func ToObject(value interface{}) (object.Object, error) {
+	return toObject(reflect.ValueOf(value))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	return toMonkey(v, map[reference]bool{})
+}
+
+// reference identifies a pointer, map or slice by its address and type.
+type reference struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// toMonkey converts v like toObject. inside holds the pointers, maps and
+// slices being converted, to report a value that refers to itself instead
+// of recursing forever.
+func toMonkey(v reflect.Value, inside map[reference]bool) (object.Object, error) {
+	if !v.IsValid() {
+		return eval.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return eval.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return eval.NULL, nil
+		}
+		return newInteger(v.Interface().(*big.Int)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if !v.IsNil() && (v.Kind() != reflect.Slice || v.Len() > 0) {
+			ref := reference{ptr: v.Pointer(), typ: v.Type()}
+			if inside[ref] {
+				return nil, fmt.Errorf("cannot convert %s that refers to itself", v.Type())
+			}
+			inside[ref] = true
+			defer delete(inside, ref)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return eval.TRUE, nil
+		}
+		return eval.FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return newInteger(new(big.Int).SetUint64(v.Uint())), nil
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return eval.NULL, nil
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toMonkey(v.Index(i), inside)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return eval.NULL, nil
+		}
+
+		pairs := make(map[object.HashKey]object.HashPair)
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toMonkey(iter.Key(), inside)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+
+			value, err := toMonkey(iter.Value(), inside)
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+
+	case reflect.Struct:
+		pairs := make(map[object.HashKey]object.HashPair)
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+
+			value, err := toMonkey(v.Field(i), inside)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %s", name, err)
+			}
+
+			key := &object.String{Value: name}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+
+	case reflect.Func:
+		if v.IsNil() {
+			return eval.NULL, nil
+		}
+		return newBuiltin(v.Type().String(), v.Interface())
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return eval.NULL, nil
+		}
+		return toMonkey(v.Elem(), inside)
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+}
+
+// FromObject converts a Monkey object to a Go value. Integers become
+// int64, or *big.Int if they do not fit, floats float64, strings string, booleans bool, null nil, arrays []interface{} and
+// hashes map[interface{}]interface{}. Other objects, such as functions, are
+// returned unchanged, and so is an array or hash inside itself where it
+// repeats.
+func FromObject(obj object.Object) interface{} {
+	return fromMonkey(obj, map[object.Object]bool{})
+}
+
+// fromMonkey converts obj like FromObject. inside holds the arrays and
+// hashes being converted, to stop at one that contains itself.
+func fromMonkey(obj object.Object, inside map[object.Object]bool) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.BigInteger:
+		return new(big.Int).Set(obj.Value)
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		if inside[obj] {
+			return obj
+		}
+		inside[obj] = true
+		defer delete(inside, obj)
+
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = fromMonkey(element, inside)
+		}
+		return elements
+	case *object.Hash:
+		if inside[obj] {
+			return obj
+		}
+		inside[obj] = true
+		defer delete(inside, obj)
+
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs[fromMonkey(pair.Key, inside)] = fromMonkey(pair.Value, inside)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
+
+// Decode stores obj in the value out points to, converting it like the
+// arguments of registered functions.
+func Decode(obj object.Object, out interface{}) error {
+	ptr := reflect.ValueOf(out)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("cannot decode into %T: not a non-nil pointer", out)
+	}
+
+	value, err := fromObject(obj, ptr.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	ptr.Elem().Set(value)
+	return nil
+}
+
+// fromObject converts obj to a value of type t.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if t != emptyInterfaceType && reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	if t.Kind() == reflect.Interface {
+		value := FromObject(obj)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		if !reflect.TypeOf(value).AssignableTo(t) {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		return reflect.ValueOf(value), nil
+	}
+
+	if _, ok := obj.(*object.Null); ok {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, mismatch(obj, t)
+	}
+
+	if t == bigIntType {
+		switch integer := obj.(type) {
+		case *object.Integer:
+			return reflect.ValueOf(big.NewInt(integer.Value)), nil
+		case *object.BigInteger:
+			return reflect.ValueOf(new(big.Int).Set(integer.Value)), nil
+		}
+		return reflect.Value{}, mismatch(obj, t)
+	}
+
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Bool:
+		boolean, ok := obj.(*object.Boolean)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		v.SetBool(boolean.Value)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*object.BigInteger); ok {
+			return reflect.Value{}, fmt.Errorf("%s overflows %s", integer.Value, t)
+		}
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		if v.OverflowInt(integer.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", integer.Value, t)
+		}
+		v.SetInt(integer.Value)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if integer, ok := obj.(*object.BigInteger); ok {
+			if !integer.Value.IsUint64() || v.OverflowUint(integer.Value.Uint64()) {
+				return reflect.Value{}, fmt.Errorf("%s overflows %s", integer.Value, t)
+			}
+			v.SetUint(integer.Value.Uint64())
+			break
+		}
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		if integer.Value < 0 || v.OverflowUint(uint64(integer.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s", integer.Value, t)
+		}
+		v.SetUint(uint64(integer.Value))
+
+	case reflect.Float32, reflect.Float64:
+		switch number := obj.(type) {
+		case *object.Float:
+			v.SetFloat(number.Value)
+		case *object.Integer:
+			v.SetFloat(float64(number.Value))
+		case *object.BigInteger:
+			f, _ := new(big.Float).SetInt(number.Value).Float64()
+			v.SetFloat(f)
+		default:
+			return reflect.Value{}, mismatch(obj, t)
+		}
+
+	case reflect.String:
+		str, ok := obj.(*object.String)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		v.SetString(str.Value)
+
+	case reflect.Slice:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		v.Set(reflect.MakeSlice(t, len(array.Elements), len(array.Elements)))
+		for i, element := range array.Elements {
+			value, err := fromObject(element, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.Index(i).Set(value)
+		}
+
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		v.Set(reflect.MakeMapWithSize(t, len(hash.Pairs)))
+		for _, pair := range hash.Pairs {
+			key, err := fromObject(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value, err := fromObject(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v.SetMapIndex(key, value)
+		}
+
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return reflect.Value{}, mismatch(obj, t)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+
+			key := &object.String{Value: name}
+			pair, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				continue
+			}
+
+			value, err := fromObject(pair.Value, t.Field(i).Type)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %s", name, err)
+			}
+			v.Field(i).Set(value)
+		}
+
+	case reflect.Ptr:
+		elem, err := fromObject(obj, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+
+	default:
+		return reflect.Value{}, mismatch(obj, t)
+	}
+
+	return v, nil
+}
+
+// fieldName returns the hash key a struct field maps to: its name, or the
+// name given in a `monkey:"name"` tag. Unexported fields and fields tagged
+// `monkey:"-"` are skipped.
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	name := field.Tag.Get("monkey")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return name, true
+	}
+}
+
+func mismatch(obj object.Object, t reflect.Type) error {
+	return fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+}
+
+// newInteger converts value to an Integer, or to a BigInteger holding a
+// copy of it if it does not fit in an int64.
+func newInteger(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInteger{Value: new(big.Int).Set(value)}
+}
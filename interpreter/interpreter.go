@@ -0,0 +1,246 @@
+// Package interpreter embeds Monkey in Go programs. Each Interpreter owns
+// its bindings, macros, builtins and output, so several of them can run
+// side by side in one process without affecting each other.
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"os"
+	"strings"
+	"time"
+)
+
+// Options configures a new Interpreter. The zero value is ready to use.
+type Options struct {
+	// Stdout receives the output of puts. It defaults to os.Stdout.
+	Stdout io.Writer
+	// Stderr receives the diagnostics written by PrintError. It defaults
+	// to os.Stderr.
+	Stderr io.Writer
+
+	// MaxSteps, MaxCallDepth, MaxAllocated and Timeout bound every Eval
+	// and Call, as described in eval.Limits. Exceeding them fails with a
+	// *RuntimeError whose Err.Limit is set.
+	MaxSteps     int64
+	MaxCallDepth int
+	MaxAllocated int64
+	Timeout      time.Duration
+
+	// ModulePath lists the directories searched for imported modules.
+	// Relative imports such as "./util" are resolved against the
+	// directory of the file given to EvalFile, or the working directory.
+	// Files outside these directories cannot be imported, nor can absolute
+	// paths. If ModulePath is nil, programs cannot import at all, so they
+	// cannot read files.
+	ModulePath []string
+}
+
+// Stats describes the resources used by evaluations.
+type Stats struct {
+	Steps     int64 // nodes evaluated
+	Allocated int64 // approximate bytes of new strings, arrays, hashes and big integers
+}
+
+type Interpreter struct {
+	options Options
+
+	// builtins encloses both env and macroEnv, so the functions in it are
+	// visible to programs and to macro bodies alike.
+	builtins *object.Environment
+	env      *object.Environment
+	macroEnv *object.Environment
+	// loader imports modules, or is nil if imports are disabled.
+	loader *module.Loader
+
+	last  Stats
+	total Stats
+}
+
+// New returns an interpreter with the standard builtins and no other
+// bindings. options may be nil.
+func New(options *Options) *Interpreter {
+	in := &Interpreter{}
+	if options != nil {
+		in.options = *options
+	}
+	if in.options.Stdout == nil {
+		in.options.Stdout = os.Stdout
+	}
+	if in.options.Stderr == nil {
+		in.options.Stderr = os.Stderr
+	}
+
+	in.builtins = object.NewEnvironment()
+	for name, builtin := range eval.NewBuiltins(in.options.Stdout) {
+		in.builtins.Set(name, builtin)
+	}
+
+	in.env = object.NewEnclosedEnvironment(in.builtins)
+	in.macroEnv = object.NewEnclosedEnvironment(in.builtins)
+
+	// Modules see the builtins, including registered ones, and share the
+	// budget of the evaluation that imports them.
+	if in.options.ModulePath != nil {
+		in.loader = &module.Loader{Path: in.options.ModulePath, Globals: in.builtins, Confined: true}
+		in.builtins.SetImporter(in.loader)
+	}
+
+	return in
+}
+
+// Stdout returns the writer puts prints to.
+func (in *Interpreter) Stdout() io.Writer {
+	return in.options.Stdout
+}
+
+// Stderr returns the writer diagnostics are printed to.
+func (in *Interpreter) Stderr() io.Writer {
+	return in.options.Stderr
+}
+
+// Set binds name to value in the global environment, converting value to a
+// Monkey object as described in ToObject.
+func (in *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the value bound to name in the global environment.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	return in.env.Get(name)
+}
+
+// Eval runs src and returns the value of its last statement, which is nil
+// if there is none. Bindings and macros made by src stay visible to later
+// calls.
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	return in.EvalFileContext(context.Background(), "", src)
+}
+
+// EvalFile is like Eval but reports positions in filename.
+func (in *Interpreter) EvalFile(filename, src string) (object.Object, error) {
+	return in.EvalFileContext(context.Background(), filename, src)
+}
+
+// EvalContext is like Eval but stops when ctx is done.
+func (in *Interpreter) EvalContext(ctx context.Context, src string) (object.Object, error) {
+	return in.EvalFileContext(ctx, "", src)
+}
+
+// EvalFileContext is like EvalFile but stops when ctx is done.
+func (in *Interpreter) EvalFileContext(ctx context.Context, filename, src string) (object.Object, error) {
+	l := lexer.NewFile(filename, src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Source: src, Errors: p.Errors()}
+	}
+
+	if in.loader != nil {
+		defer in.loader.EnterMain(filename)()
+	}
+
+	budget, done := in.newBudget(ctx)
+	defer done()
+
+	// Macros run before the program, but count towards its limits.
+	previous := in.macroEnv.Budget()
+	in.macroEnv.SetBudget(budget)
+	eval.DefineMacros(program, in.macroEnv)
+	expanded := eval.ExpandMacros(program, in.macroEnv)
+	in.macroEnv.SetBudget(previous)
+
+	evaluated := eval.EvalBudget(expanded, in.env, budget)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, &RuntimeError{Source: src, Err: errObj}
+	}
+
+	return evaluated, nil
+}
+
+// Stats returns the resources used by all evaluations of the interpreter
+// so far.
+func (in *Interpreter) Stats() Stats {
+	return in.total
+}
+
+// LastStats returns the resources used by the most recent Eval or
+// CallContext, whether or not it succeeded.
+func (in *Interpreter) LastStats() Stats {
+	return in.last
+}
+
+// newBudget returns the budget for one Eval or Call. done releases it and
+// adds what was used to the statistics.
+func (in *Interpreter) newBudget(ctx context.Context) (budget *object.Budget, done func()) {
+	budget, cancel := eval.NewBudget(ctx, eval.Limits{
+		MaxSteps:     in.options.MaxSteps,
+		MaxCallDepth: in.options.MaxCallDepth,
+		MaxAllocated: in.options.MaxAllocated,
+		Timeout:      in.options.Timeout,
+	})
+
+	return budget, func() {
+		cancel()
+
+		in.last = Stats{Steps: budget.Steps, Allocated: budget.Allocated}
+		in.total.Steps += budget.Steps
+		in.total.Allocated += budget.Allocated
+	}
+}
+
+// PrintError writes err to Stderr. Parse and runtime errors are shown with
+// the offending source line, like in the REPL.
+func (in *Interpreter) PrintError(err error) {
+	switch err := err.(type) {
+	case *ParseError:
+		repl.PrintParserErrors(in.options.Stderr, err.Source, err.Errors)
+	case *RuntimeError:
+		repl.PrintRuntimeError(in.options.Stderr, err.Source, err.Err)
+	default:
+		fmt.Fprintln(in.options.Stderr, err)
+	}
+}
+
+// ParseError is returned when the source does not parse.
+type ParseError struct {
+	Source string
+	Errors []*parser.ParseError
+}
+
+func (e *ParseError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// RuntimeError is returned when the program raises an error.
+type RuntimeError struct {
+	Source string
+	Err    *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if !e.Err.Span.IsValid() {
+		return e.Err.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Err.Span.Start, e.Err.Message)
+}
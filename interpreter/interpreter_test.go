@@ -0,0 +1,450 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	var outA, outB bytes.Buffer
+	a := New(&Options{Stdout: &outA})
+	b := New(&Options{Stdout: &outB})
+
+	if _, err := a.Eval(`let x = 1; let unless = macro(c, x) { quote(if (!(unquote(c))) { unquote(x) }) };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := a.Register("double", func(n int) int { return n * 2 }); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := a.Eval(`puts(unless(false, double(x)))`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if outA.String() != "2\n" {
+		t.Errorf("wrong output of a. got=%q", outA.String())
+	}
+
+	for _, input := range []string{"x", "double(1)", "unless(false, 1)"} {
+		_, err := b.Eval(input)
+		if _, ok := err.(*RuntimeError); !ok {
+			t.Errorf("%q should fail in b. got=%v", input, err)
+		}
+	}
+
+	if _, err := b.Eval(`puts("b")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if outB.String() != "b\n" || outA.String() != "2\n" {
+		t.Errorf("wrong output. a=%q, b=%q", outA.String(), outB.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New(nil)
+
+	register := map[string]interface{}{
+		"add":  func(a, b int64) int64 { return a + b },
+		"join": func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+		"keys": func(m map[string]int) []string { return []string{"n"} },
+		"sum": func(xs []int) (total int) {
+			for _, x := range xs {
+				total += x
+			}
+			return
+		},
+		"fail":    func() error { return errors.New("boom") },
+		"check":   func(n uint8) (bool, error) { return n > 1, nil },
+		"noop":    func() {},
+		"sqrt":    math.Sqrt,
+		"double":  func(n *big.Int) *big.Int { return n.Mul(n, big.NewInt(2)) },
+		"maxUint": func() uint64 { return math.MaxUint64 },
+		"half":    func(x float32) float32 { return x / 2 },
+		"kind":    func(v interface{}) string { return reflect.TypeOf(v).String() },
+		"apply":   func(fn object.Object) string { return string(fn.Type()) },
+		"toHash":  func() map[string][]bool { return map[string][]bool{"a": {true}} },
+		"nothing": func() object.Object { return nil },
+	}
+	for name, fn := range register {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatalf("Register(%q): %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`add(1, 2)`, "3"},
+		{`join("-", "a", "b", "c")`, "a-b-c"},
+		{`join(",")`, ""},
+		{`keys({"n": 1})`, "[n]"},
+		{`sum([1, 2, 3])`, "6"},
+		{`check(2)`, "true"},
+		{`noop()`, "null"},
+		{`sqrt(2.25)`, "1.5"},
+		{`sqrt(16)`, "4.0"},
+		{`double(99999999999999999999)`, "199999999999999999998"},
+		{`double(2)`, "4"},
+		{`maxUint() + 1`, "18446744073709551616"},
+		{`check(maxUint())`, "ERROR: check: argument 1: 18446744073709551615 overflows uint8"},
+		{`add(maxUint(), 1)`, "ERROR: add: argument 1: 18446744073709551615 overflows int64"},
+		{`half(3)`, "1.5"},
+		{`kind(1.5)`, "float64"},
+		{`kind([1, "a"])`, "[]interface {}"},
+		{`kind({1: 2})`, "map[interface {}]interface {}"},
+		{`apply(fn(x) { x })`, "FUNCTION"},
+		{`toHash()["a"]`, "[true]"},
+		{`nothing()`, "null"},
+		{`fail()`, "ERROR: boom"},
+		{`add(1)`, "ERROR: add: wrong number of arguments: want=2, got=1"},
+		{`add(1, "2")`, "ERROR: add: argument 2: cannot use STRING as int64"},
+		{`check(256)`, "ERROR: check: argument 1: 256 overflows uint8"},
+		{`sum([1, true])`, "ERROR: sum: argument 1: cannot use BOOLEAN as int"},
+		{`sum([1.5])`, "ERROR: sum: argument 1: cannot use FLOAT as int"},
+	}
+
+	for _, tt := range tests {
+		result, err := in.Eval(tt.input)
+
+		var got string
+		if runtimeErr, ok := err.(*RuntimeError); ok {
+			got = "ERROR: " + runtimeErr.Err.Message
+		} else if err != nil {
+			t.Fatalf("%q: unexpected error: %s", tt.input, err)
+		} else {
+			got = result.Inspect()
+		}
+
+		if got != tt.expected {
+			t.Errorf("%q: wrong result. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRegisterRejectsBadFunctions(t *testing.T) {
+	in := New(nil)
+
+	for _, fn := range []interface{}{
+		42,
+		func() (int, int) { return 1, 2 },
+		func() (int, error, error) { return 1, nil, nil },
+	} {
+		if err := in.Register("bad", fn); err == nil {
+			t.Errorf("expected an error registering %T", fn)
+		}
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	in := New(nil)
+
+	if err := in.Set("config", map[string]interface{}{"name": "monkey", "ports": []int{80, 443}}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := in.Eval(`config["ports"][1]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "443" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	if _, err := in.Eval(`let greeting = "hi " + config["name"];`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	greeting, ok := in.Get("greeting")
+	if !ok || FromObject(greeting) != "hi monkey" {
+		t.Errorf("wrong greeting. got=%v", greeting)
+	}
+
+	if err := in.Set("bad", make(chan int)); err == nil {
+		t.Errorf("expected an error setting a channel")
+	}
+
+	type node struct {
+		Value int
+		Next  *node
+	}
+	shared := &node{Value: 1}
+	if err := in.Set("pair", []*node{shared, shared}); err != nil {
+		t.Errorf("unexpected error for a value referred to twice: %s", err)
+	}
+
+	loop := &node{}
+	loop.Next = loop
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+	selfSlice := []interface{}{nil}
+	selfSlice[0] = selfSlice
+
+	for _, value := range []interface{}{loop, selfMap, selfSlice} {
+		err := in.Set("cycle", value)
+		if err == nil || !strings.Contains(err.Error(), "refers to itself") {
+			t.Errorf("expected an error setting %T that refers to itself. got=%v", value, err)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var stderr bytes.Buffer
+	in := New(&Options{Stderr: &stderr})
+
+	_, err := in.EvalFile("main.mk", "let = 1;")
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("expected a parse error. got=%T (%v)", err, err)
+	}
+	if err.Error() != "main.mk:1:5: expected identifier, got = instead" {
+		t.Errorf("wrong message. got=%q", err.Error())
+	}
+
+	_, err = in.EvalFile("main.mk", "1 + true")
+	if _, ok := err.(*RuntimeError); !ok {
+		t.Fatalf("expected a runtime error. got=%T (%v)", err, err)
+	}
+	if err.Error() != "main.mk:1:1: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong message. got=%q", err.Error())
+	}
+
+	in.PrintError(err)
+	expected := "Traceback (most recent call last):\n" +
+		"  at main.mk:1:1 in <main>\n" +
+		"Error: type mismatch: INTEGER + BOOLEAN\n" +
+		" --> main.mk:1:1\n  |\n1 | 1 + true\n  | ^^^^^^^^\n"
+	if stderr.String() != expected {
+		t.Errorf("wrong stderr. want=%q, got=%q", expected, stderr.String())
+	}
+}
+
+func TestModules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-interpreter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := "export let greet = fn(name) { shout(\"hi \" + name) };\n" +
+		"export let spin = fn() { while (true) { } };\n" +
+		"export let fail = fn() { 1 + true };\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "greet.mk"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = New(nil).Eval(`import "greet"`)
+	if err == nil || err.Error() != "1:1: cannot import \"greet\": imports are disabled" {
+		t.Errorf("imports should be disabled without ModulePath. got=%v", err)
+	}
+
+	var stderr bytes.Buffer
+	in := New(&Options{ModulePath: []string{dir}, MaxSteps: 1000, Stderr: &stderr})
+	if err := in.Register("shout", strings.ToUpper); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := in.Eval(`import "greet"; greet["greet"]("bob")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "HI BOB" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	_, err = in.Eval(`greet["spin"]()`)
+	if err, ok := err.(*RuntimeError); !ok || err.Err.Limit != object.StepLimit {
+		t.Errorf("expected the step limit to stop the module. got=%v", err)
+	}
+
+	_, err = in.EvalFile("main.mk", "greet[\"fail\"]()")
+	in.PrintError(err)
+	expected := "Traceback (most recent call last):\n" +
+		"  at main.mk:1:1 in <main>\n" +
+		"  at " + filepath.Join(dir, "greet.mk") + ":3:26 in fail\n" +
+		"Error: type mismatch: INTEGER + BOOLEAN\n" +
+		" --> main.mk:1:1\n  |\n1 | greet[\"fail\"]()\n  | ^^^^^^^^^^^^^^^\n"
+	if stderr.String() != expected {
+		t.Errorf("wrong stderr. want=%q, got=%q", expected, stderr.String())
+	}
+}
+
+func TestModulesAreConfined(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-interpreter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"mods/leak.mk":    `import "../secret/token"`,
+		"mods/util.mk":    `export let one = 1`,
+		"app/local.mk":    `export let two = 2`,
+		"secret/token.mk": `secret_token_abc123`,
+	}
+	for name, src := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := New(&Options{ModulePath: []string{filepath.Join(dir, "mods")}})
+	main := filepath.Join(dir, "app", "main.mk")
+
+	result, err := in.EvalFile(main, `import "util"; import "./local"; util["one"] + local["two"]`)
+	if err != nil || result.Inspect() != "3" {
+		t.Fatalf("wrong result. got=%v, %v", result, err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "` + filepath.ToSlash(filepath.Join(dir, "secret", "token")) + `"`, "absolute import paths are not allowed"},
+		{`import "../secret/token"`, "is outside the module path"},
+		{`import "leak"`, "is outside the module path"},
+	}
+
+	for _, tt := range tests {
+		_, err := in.EvalFile(main, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) || strings.Contains(err.Error(), "secret_token") {
+			t.Errorf("%s: expected the import to be rejected. got=%v", tt.input, err)
+		}
+	}
+}
+
+func TestMainFileIsNotImportedAgain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-interpreter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `puts("self"); import "./self";`
+	main := filepath.Join(dir, "self.mk")
+	if err := ioutil.WriteFile(main, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	in := New(&Options{Stdout: &out, ModulePath: []string{}})
+
+	_, err = in.EvalFile(main, src)
+	if err == nil || !strings.Contains(err.Error(), "import cycle") {
+		t.Errorf("expected an import cycle. got=%v", err)
+	}
+	if out.String() != "self\n" {
+		t.Errorf("the main file ran more than once. output=%q", out.String())
+	}
+}
+
+func TestLimits(t *testing.T) {
+	in := New(&Options{MaxSteps: 500, Timeout: time.Second})
+
+	_, err := in.Eval("let loop = fn(n) { loop(n + 1) }; loop(0)")
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected a runtime error. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Err.Limit != object.StepLimit {
+		t.Errorf("wrong limit. got=%q", runtimeErr.Err.Limit)
+	}
+
+	// Each Eval gets a fresh budget.
+	result, err := in.Eval("1 + 1")
+	if err != nil || result.Inspect() != "2" {
+		t.Fatalf("wrong result. got=%v, %v", result, err)
+	}
+
+	loop, _ := in.Get("loop")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The step limit trips before the context is first checked.
+	_, err = in.CallContext(ctx, loop, 0)
+	runtimeErr, ok = err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected a runtime error. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Err.Limit != object.StepLimit {
+		t.Errorf("wrong limit. got=%q", runtimeErr.Err.Limit)
+	}
+
+	in = New(nil)
+	if _, err := in.Eval("let loop = fn(n) { loop(n + 1) };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	loop, _ = in.Get("loop")
+
+	_, err = in.CallContext(ctx, loop, 0)
+	runtimeErr, ok = err.(*RuntimeError)
+	if !ok || runtimeErr.Err.Limit != object.CanceledLimit {
+		t.Errorf("expected the call to be canceled. got=%v", err)
+	}
+
+	// Macros count towards the limits of the evaluation expanding them.
+	macros := []struct {
+		options Options
+		input   string
+		limit   object.LimitKind
+	}{
+		{Options{MaxSteps: 500}, "let m = macro() { while (true) {}; quote(1) }; m()", object.StepLimit},
+		{Options{Timeout: 50 * time.Millisecond}, "let m = macro() { while (true) {}; quote(1) }; m()", object.DeadlineLimit},
+		{Options{MaxAllocated: 64 << 10}, `let m = macro() { let s = "x"; while (true) { s += s }; quote(1) }; try { m() } catch (e) { 1 }`, object.MemoryLimit},
+	}
+
+	for _, tt := range macros {
+		options := tt.options
+		_, err := New(&options).Eval(tt.input)
+		runtimeErr, ok := err.(*RuntimeError)
+		if !ok || runtimeErr.Err.Limit != tt.limit {
+			t.Errorf("%q: expected the %s limit to be exceeded. got=%v", tt.input, tt.limit, err)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	in := New(&Options{MaxAllocated: 1000})
+
+	if _, err := in.Eval(`let greet = fn(name) { "hello " + name };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	first := in.LastStats()
+
+	greet, _ := in.Get("greet")
+	if _, err := in.CallContext(context.Background(), greet, "monkey"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// "hello " and "hello monkey"; the argument was allocated by the host.
+	second := in.LastStats()
+	if second.Allocated != (16+16+6)+(16+16+12) {
+		t.Errorf("wrong allocation count. got=%d", second.Allocated)
+	}
+
+	total := in.Stats()
+	if total.Steps != first.Steps+second.Steps || total.Allocated != first.Allocated+second.Allocated {
+		t.Errorf("wrong totals. first=%+v, second=%+v, total=%+v", first, second, total)
+	}
+
+	_, err := in.Eval(`let grow = fn(s) { grow(s + s) }; grow("x")`)
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok || runtimeErr.Err.Limit != object.MemoryLimit {
+		t.Fatalf("expected the allocation limit to trip. got=%v", err)
+	}
+	if in.LastStats().Allocated <= 1000 {
+		t.Errorf("stats should include the failed run. got=%+v", in.LastStats())
+	}
+}
@@ -1,35 +1,131 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 type Lexer struct {
 	input        string
+	filename     string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char, counted in runes
+
+	doc       []string // lines of the /// comment being collected
+	docLine   int      // line of the last doc comment line
+	tokenLine int      // line of the last token
+	errors    []*Error
+
+	// interpolations holds the ${ of strings that are still open,
+	// innermost last.
+	interpolations []interpolation
+}
+
+type interpolation struct {
+	start  token.Position // the string's opening quote
+	braces int            // '{' opened inside the ${ and not yet closed
+}
+
+// Error describes input the lexer cannot turn into tokens.
+type Error struct {
+	Span    token.Span
+	Message string
+	// Incomplete is set when more input could fix the error, as with a
+	// comment that is not closed yet.
+	Incomplete bool
+}
+
+func (e *Error) Error() string {
+	return e.Span.Start.String() + ": " + e.Message
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile returns a lexer whose token positions refer to filename. A
+// leading byte order mark is ignored, and a leading "#!" line is skipped
+// so scripts can be made executable.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
+
+	if l.ch == byteOrderMark {
+		l.readChar()
+		l.column = 1
+	}
+
+	if l.ch == '#' && l.peekChar() == '!' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	}
+
 	return l
 }
 
+const byteOrderMark = '\uFEFF'
+
+// readChar decodes the next rune of the input. Bytes that are not valid
+// UTF-8 are read one at a time as utf8.RuneError.
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += width
+	l.column += 1
+}
+
+// pos returns the position of the current char.
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
+// Errors returns the errors found so far.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// NextToken returns the next token, skipping whitespace and comments.
 func (l *Lexer) NextToken() token.Token {
+	l.skipWhitespaceAndComments()
+	doc := l.takeDoc()
+
+	tok := l.readToken()
+	tok.Doc = doc
+	l.tokenLine = tok.Span.Start.Line
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	start := l.pos()
 
 	switch l.ch {
 	case '(':
@@ -37,25 +133,39 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1].braces == 0 {
+			open := l.interpolations[n-1]
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readStringPart(open.start, token.STRING_MIDDLE, token.STRING_TAIL)
+			break
+		}
+		if n > 0 {
+			l.interpolations[n-1].braces--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case '=':
-		if l.peekChar() == '=' {
-			c := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(c) + string(l.ch)}
-		} else {
+		switch l.peekChar() {
+		case '=':
+			tok = l.twoCharToken(token.EQ)
+		case '>':
+			tok = l.twoCharToken(token.ARROW)
+		default:
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.operatorToken(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.operatorToken(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if l.peekChar() == '=' {
 			c := l.ch
@@ -65,13 +175,55 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.twoCharToken(token.POWER)
+		} else {
+			tok = l.operatorToken(token.ASTERISK, token.ASTERISK_ASSIGN)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		tok = l.operatorToken(token.SLASH, token.SLASH_ASSIGN)
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.twoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.twoCharToken(token.SHIFT_LEFT)
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.twoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.twoCharToken(token.SHIFT_RIGHT)
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.twoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.twoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], token.ELLIPSIS) {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: token.ELLIPSIS}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
@@ -79,19 +231,26 @@ func (l *Lexer) NextToken() token.Token {
 	case ':':
 		tok = newToken(token.COLON, l.ch)
 	case '"':
-		str := l.readString()
+		tok = l.readStringPart(start, token.STRING_HEAD, token.STRING)
+	case '`':
+		str := l.readRawString()
 		tok = token.Token{Type: token.STRING, Literal: str}
 	case 0:
+		if len(l.interpolations) > 0 {
+			l.unterminated(l.interpolations[0].start, "unterminated string literal")
+			l.interpolations = nil
+		}
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Span = token.Span{Start: start, End: l.pos()}
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Span = token.Span{Start: start, End: l.pos()}
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -99,42 +258,261 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Span = token.Span{Start: start, End: l.pos()}
 	return tok
 }
 
-func (l *Lexer) readString() string {
+// readStringPart reads a double-quoted string, or the rest of one after
+// an interpolation, with the escape sequences replaced. It stops on the
+// closing quote and returns a token of type last, or on the '{' of a ${
+// and returns a token of type open. start is the string's opening quote.
+func (l *Lexer) readStringPart(start token.Position, open, last token.TokenType) token.Token {
+	var out strings.Builder
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '"':
+			return token.Token{Type: last, Literal: out.String()}
+		case 0:
+			l.unterminated(start, "unterminated string literal")
+			return token.Token{Type: last, Literal: out.String()}
+		case '\\':
+			l.readEscape(&out)
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteRune(l.ch)
+				continue
+			}
+			l.readChar()
+			l.interpolations = append(l.interpolations, interpolation{start: start})
+			return token.Token{Type: open, Literal: out.String()}
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
+}
+
+// readEscape reads the escape sequence starting at the backslash under
+// examination and writes the character it stands for to out.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.pos()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '\\', '"', '$':
+		out.WriteRune(l.ch)
+	case 'u':
+		l.readUnicodeEscape(start, out)
+	case 0:
+		// The caller reports the unterminated string.
+	default:
+		l.errors = append(l.errors, &Error{
+			Span:    token.Span{Start: start, End: l.next()},
+			Message: fmt.Sprintf("unknown escape sequence \\%c", l.ch),
+		})
+		out.WriteRune(l.ch)
+	}
+}
+
+// readUnicodeEscape reads the {hex} part of a \u{...} escape, which names a
+// code point with one to six hex digits.
+func (l *Lexer) readUnicodeEscape(start token.Position, out *strings.Builder) {
+	value, digits := 0, 0
+
+	if l.peekChar() == '{' {
+		l.readChar()
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+			if digits < 7 {
+				value = value*16 + hexValue(l.ch)
+			}
+			digits++
+		}
+	}
+
+	if l.peekChar() != '}' || digits == 0 || digits > 6 || !utf8.ValidRune(rune(value)) {
+		l.errors = append(l.errors, &Error{
+			Span:    token.Span{Start: start, End: l.next()},
+			Message: "invalid Unicode escape, want \\u{...} with 1 to 6 hex digits",
+		})
+		return
+	}
+
+	l.readChar()
+	out.WriteRune(rune(value))
+}
+
+// readRawString reads a string enclosed in backticks, which may span lines
+// and has no escape sequences.
+func (l *Lexer) readRawString() string {
+	start := l.pos()
 	position := l.position + 1
 
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch l.ch {
+		case '`':
+			return l.input[position:l.position]
+		case 0:
+			l.unterminated(start, "unterminated raw string literal")
+			return l.input[position:l.position]
 		}
 	}
+}
 
-	return l.input[position:l.position]
+// unterminated reports a literal or comment starting at start that is
+// still open at the end of the input.
+func (l *Lexer) unterminated(start token.Position, message string) {
+	l.errors = append(l.errors, &Error{
+		Span:       token.Span{Start: start, End: l.pos()},
+		Message:    message,
+		Incomplete: true,
+	})
 }
 
+// next returns the position just after the current char.
+func (l *Lexer) next() token.Position {
+	pos := l.pos()
+	if l.ch != 0 {
+		pos.Offset = l.readPosition
+		pos.Column++
+	}
+	return pos
+}
+
+// readIdentifier reads a letter or underscore followed by any number of
+// letters, underscores, digits, combining marks and connector punctuation,
+// in any script.
 func (l *Lexer) readIdentifier() string {
-	var literal string
+	position := l.position
 
-	for isLetter(l.ch) {
-		literal += string(l.ch)
+	for isLetter(l.ch) || isIdentifierPart(l.ch) {
 		l.readChar()
 	}
 
-	return literal
+	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
-	var literal string
+// readNumber reads an integer or a float. A float has a fraction, an
+// exponent or both, as in 3.14, 1e9 or 2.5E-3. The dot must be followed by
+// a digit, so 1.foo still lexes as the integer 1.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
 
+	if (l.ch == 'e' || l.ch == 'E') && l.isExponent() {
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
-		literal += string(l.ch)
 		l.readChar()
 	}
+}
+
+// isExponent reports whether the 'e' or 'E' under examination starts an
+// exponent, that is, whether digits follow it, optionally after a sign.
+func (l *Lexer) isExponent() bool {
+	next := l.readPosition
+	if next < len(l.input) && (l.input[next] == '+' || l.input[next] == '-') {
+		next++
+	}
+
+	return next < len(l.input) && isDigit(rune(l.input[next]))
+}
+
+func (l *Lexer) skipWhitespaceAndComments() {
+	for {
+		l.skipWhitespace()
+
+		switch {
+		case l.ch == '/' && l.peekChar() == '/':
+			l.skipLineComment()
+		case l.ch == '/' && l.peekChar() == '*':
+			l.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// skipLineComment skips a // comment up to the end of the line. A ///
+// comment on a line of its own is collected as documentation for the next
+// token.
+func (l *Lexer) skipLineComment() {
+	line := l.line
+	position := l.position
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	text := strings.TrimSuffix(l.input[position:l.position], "\r")
+	if !strings.HasPrefix(text, "///") || strings.HasPrefix(text, "////") || line == l.tokenLine {
+		l.doc = nil
+		return
+	}
 
-	return literal
+	if line != l.docLine+1 {
+		l.doc = nil
+	}
+	l.doc = append(l.doc, strings.TrimPrefix(text[3:], " "))
+	l.docLine = line
+}
+
+func (l *Lexer) skipBlockComment() {
+	l.doc = nil
+	start := l.pos()
+
+	l.readChar()
+	l.readChar()
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.unterminated(start, "unterminated block comment")
+			return
+		}
+		l.readChar()
+	}
+
+	l.readChar()
+	l.readChar()
+}
+
+// takeDoc returns the doc comment ending on the line above the current
+// char, if any, and starts collecting the next one.
+func (l *Lexer) takeDoc() string {
+	doc := l.doc
+	l.doc = nil
+
+	if doc == nil || l.line != l.docLine+1 {
+		return ""
+	}
+	return strings.Join(doc, "\n")
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -143,22 +521,66 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
 
-	return l.input[l.readPosition]
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
+}
+
+// operatorToken returns the compound assignment token if the operator is
+// followed by '=', and the plain operator token otherwise.
+func (l *Lexer) operatorToken(plain, assign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		return l.twoCharToken(assign)
+	}
+
+	return newToken(plain, l.ch)
+}
+
+// twoCharToken consumes the next character and returns a token of type t
+// made of it and the current one.
+func (l *Lexer) twoCharToken(t token.TokenType) token.Token {
+	c := l.ch
+	l.readChar()
+	return token.Token{Type: t, Literal: string(c) + string(l.ch)}
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
+}
+
+// isIdentifierPart reports whether ch may continue an identifier, as in
+// Unicode Standard Annex #31: besides letters, these are digits, the marks
+// that scripts such as Devanagari and Thai combine with letters, and
+// connector punctuation.
+func isIdentifierPart(ch rune) bool {
+	return unicode.In(ch, unicode.Nd, unicode.Mn, unicode.Mc, unicode.Pc)
 }
 
-func isDigit(ch byte) bool {
+// isDigit reports whether ch is an ASCII digit. Numbers are written with
+// ASCII digits only.
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func hexValue(ch rune) int {
+	switch {
+	case isDigit(ch):
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	default:
+		return int(ch-'A') + 10
+	}
+}
@@ -14,7 +14,7 @@ func TestNextToken(t *testing.T) {
 	};
 	
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 	
 	if (5 < 10) {
@@ -161,3 +161,436 @@ func TestNextToken(t *testing.T) {
 	}
 
 }
+
+func TestLoopKeywords(t *testing.T) {
+	input := "while for in break continue forever"
+
+	expected := []token.TokenType{
+		token.WHILE, token.FOR, token.IN, token.BREAK, token.CONTINUE, token.IDENT, token.EOF,
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want {
+			t.Fatalf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, want, tok.Type)
+		}
+	}
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	input := "a += 1 -= *= /= = + /"
+
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.PLUS_ASSIGN, Literal: "+="},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.MINUS_ASSIGN, Literal: "-="},
+		{Type: token.ASTERISK_ASSIGN, Literal: "*="},
+		{Type: token.SLASH_ASSIGN, Literal: "/="},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.PLUS, Literal: "+"},
+		{Type: token.SLASH, Literal: "/"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want.Type || tok.Literal != want.Literal {
+			t.Fatalf("tokens[%d] wrong. expected=%s %q, got=%s %q",
+				i, want.Type, want.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := "<= >= < > && || & | ^ << >> % ** * => == = ...x .."
+
+	expected := []token.TokenType{
+		token.LT_EQ, token.GT_EQ, token.LT, token.GT, token.AND, token.OR,
+		token.AMPERSAND, token.PIPE, token.CARET, token.SHIFT_LEFT, token.SHIFT_RIGHT,
+		token.PERCENT, token.POWER, token.ASTERISK, token.ARROW, token.EQ, token.ASSIGN,
+		token.ELLIPSIS, token.IDENT, token.ILLEGAL, token.ILLEGAL, token.EOF,
+	}
+
+	l := New(input)
+	for i, want := range expected {
+		tok := l.NextToken()
+		if tok.Type != want {
+			t.Fatalf("tokens[%d] - tokentype wrong. expected=%q, got=%q", i, want, tok.Type)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  \"ab\" + x;"
+
+	tests := []struct {
+		expectedType token.TokenType
+		startLine    int
+		startColumn  int
+		startOffset  int
+		endColumn    int
+	}{
+		{token.LET, 1, 1, 0, 4},
+		{token.IDENT, 1, 5, 4, 6},
+		{token.ASSIGN, 1, 7, 6, 8},
+		{token.INT, 1, 9, 8, 10},
+		{token.SEMICOLON, 1, 10, 9, 11},
+		{token.STRING, 2, 3, 13, 7},
+		{token.PLUS, 2, 8, 18, 9},
+		{token.IDENT, 2, 10, 20, 11},
+		{token.SEMICOLON, 2, 11, 21, 12},
+		{token.EOF, 2, 12, 22, 12},
+	}
+
+	l := NewFile("test.mk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		start := tok.Span.Start
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if start.Filename != "test.mk" {
+			t.Errorf("tests[%d] - filename wrong. got=%q", i, start.Filename)
+		}
+
+		if start.Line != tt.startLine || start.Column != tt.startColumn {
+			t.Errorf("tests[%d] - start wrong. expected=%d:%d, got=%d:%d",
+				i, tt.startLine, tt.startColumn, start.Line, start.Column)
+		}
+
+		if start.Offset != tt.startOffset {
+			t.Errorf("tests[%d] - offset wrong. expected=%d, got=%d",
+				i, tt.startOffset, start.Offset)
+		}
+
+		if tok.Span.End.Column != tt.endColumn {
+			t.Errorf("tests[%d] - end column wrong. expected=%d, got=%d",
+				i, tt.endColumn, tok.Span.End.Column)
+		}
+	}
+}
+
+func TestUnicodeSource(t *testing.T) {
+	input := "\uFEFFlet größe = \"€\" + 名前1;\nπ § _x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line            int
+		column          int
+		offset          int
+	}{
+		{token.LET, "let", 1, 1, 3},
+		{token.IDENT, "größe", 1, 5, 7},
+		{token.ASSIGN, "=", 1, 11, 15},
+		{token.STRING, "€", 1, 13, 17},
+		{token.PLUS, "+", 1, 17, 23},
+		{token.IDENT, "名前1", 1, 19, 25},
+		{token.SEMICOLON, ";", 1, 22, 32},
+		{token.IDENT, "π", 2, 1, 34},
+		{token.ILLEGAL, "§", 2, 3, 37},
+		{token.IDENT, "_x", 2, 5, 40},
+		{token.EOF, "", 2, 7, 42},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		start := tok.Span.Start
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q (%q), got=%q (%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if start.Line != tt.line || start.Column != tt.column || start.Offset != tt.offset {
+			t.Errorf("tests[%d] - wrong position. expected=%d:%d@%d, got=%d:%d@%d",
+				i, tt.line, tt.column, tt.offset, start.Line, start.Column, start.Offset)
+		}
+	}
+}
+
+func TestCombiningMarksInIdentifiers(t *testing.T) {
+	input := "let नमस्ते = สวัสดี + a‿b; ́x"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "नमस्ते"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "สวัสดี"},
+		{token.PLUS, "+"},
+		{token.IDENT, "a‿b"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "́"},
+		{token.IDENT, "x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q (%q), got=%q (%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestShebangLine(t *testing.T) {
+	input := "#!/usr/bin/env monkey\nputs(1);"
+
+	l := New(input)
+	tok := l.NextToken()
+
+	if tok.Type != token.IDENT || tok.Literal != "puts" {
+		t.Fatalf("shebang not skipped. got=%q (%q)", tok.Type, tok.Literal)
+	}
+
+	if tok.Span.Start.Line != 2 || tok.Span.Start.Column != 1 {
+		t.Errorf("wrong position after shebang. got=%s", tok.Span.Start)
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "5 3.14 0.5 1e9 2.5E-3 6e+2 1.foo 7e x1.5"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "foo"},
+		{token.INT, "7"},
+		{token.IDENT, "e"},
+		{token.IDENT, "x1"},
+		{token.ILLEGAL, "."},
+		{token.INT, "5"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q (%q), got=%q (%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// a line comment
+let a = 1; // trailing
+/* a block
+   comment */ let b = a /* inline */ / 2;
+/// Adds two numbers.
+///
+///  Indented.
+let add = fn(x, y) { x + y };
+/// Not attached: a blank line follows.
+
+let c = 3; /// trailing doc-like comment
+let d = 4;
+/// Broken by a plain comment.
+// plain
+let e = 5;
+//// Four slashes are a plain comment.
+let f = 6;
+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedDoc     string
+	}{
+		{token.LET, "let", ""},
+		{token.IDENT, "a", ""},
+		{token.ASSIGN, "=", ""},
+		{token.INT, "1", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.LET, "let", ""},
+		{token.IDENT, "b", ""},
+		{token.ASSIGN, "=", ""},
+		{token.IDENT, "a", ""},
+		{token.SLASH, "/", ""},
+		{token.INT, "2", ""},
+		{token.SEMICOLON, ";", ""},
+		{token.LET, "let", "Adds two numbers.\n\n Indented."},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q (%q), got=%q (%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Doc != tt.expectedDoc {
+			t.Fatalf("tests[%d] - wrong doc. expected=%q, got=%q", i, tt.expectedDoc, tok.Doc)
+		}
+	}
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Doc != "" {
+			t.Errorf("unexpected doc on %q at %s: %q", tok.Literal, tok.Span.Start, tok.Doc)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected errors: %v", l.Errors())
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("1 /* never\nclosed")
+
+	if tok := l.NextToken(); tok.Type != token.INT {
+		t.Fatalf("wrong first token. got=%q", tok.Type)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("comment not skipped. got=%q (%q)", tok.Type, tok.Literal)
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. got=%d", len(errors))
+	}
+	if errors[0].Error() != "1:3: unterminated block comment" || !errors[0].Incomplete {
+		t.Errorf("wrong error. got=%q (incomplete=%t)", errors[0].Error(), errors[0].Incomplete)
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"tab\there"`, "tab\there"},
+		{`"line\nbreak\r"`, "line\nbreak\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{41}\u{e9}\u{1F600}"`, "A\u00e9\U0001F600"},
+		{`"héllo"`, "héllo"},
+		{"`raw \\n ${x}\nsecond line`", "raw \\n ${x}\nsecond line"},
+		{"``", ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING || tok.Literal != tt.expected {
+			t.Errorf("%s: wrong token. expected=%q, got=%q (%q)", tt.input, tt.expected, tok.Type, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("%s: unexpected errors: %v", tt.input, l.Errors())
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%s: string not consumed. next=%q (%q)", tt.input, next.Type, next.Literal)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   string
+		incomplete bool
+	}{
+		{`"open`, "1:1: unterminated string literal", true},
+		{`x = "escaped quote\"`, "1:5: unterminated string literal", true},
+		{"`open\nraw", "1:1: unterminated raw string literal", true},
+		{`"\q"`, "1:2: unknown escape sequence \\q", false},
+		{`"\u41"`, "1:2: invalid Unicode escape, want \\u{...} with 1 to 6 hex digits", false},
+		{`"\u{110000}"`, "1:2: invalid Unicode escape, want \\u{...} with 1 to 6 hex digits", false},
+		{`"\u{}"`, "1:2: invalid Unicode escape, want \\u{...} with 1 to 6 hex digits", false},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%s: wrong number of errors. got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0].Error() != tt.expected || errors[0].Incomplete != tt.incomplete {
+			t.Errorf("%s: wrong error. expected=%q (incomplete=%t), got=%q (incomplete=%t)",
+				tt.input, tt.expected, tt.incomplete, errors[0].Error(), errors[0].Incomplete)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${x} b ${ {"k": 1}["k"] }" "${"in ${y}"}!" "\${x} $x"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.STRING_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_TAIL, ""},
+		{token.STRING_HEAD, ""},
+		{token.STRING_HEAD, "in "},
+		{token.IDENT, "y"},
+		{token.STRING_TAIL, ""},
+		{token.STRING_TAIL, "!"},
+		{token.STRING, "${x} $x"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q (%q), got=%q (%q)",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	l = New(`"open ${x`)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+	errors := l.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:1: unterminated string literal" || !errors[0].Incomplete {
+		t.Errorf("wrong errors for open interpolation. got=%v", errors)
+	}
+}
@@ -1,13 +1,93 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io/ioutil"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
+// Exit codes reported by the command line tool.
+const (
+	exitOK           = 0
+	exitRuntimeError = 1
+	exitUsage        = 2
+	exitParseError   = 3
+)
+
+const usage = `Usage:
+  monkey                        start the REPL (or run stdin when piped)
+  monkey run <file> [args...]   run a script
+  monkey <file> [args...]       run a script (used by "#!" lines)
+  monkey -e <expr> [args...]    evaluate an expression and print the result
+
+A file name of "-" reads the script from stdin. Arguments after the script
+are available to it as the "args" array.
+
+Options:
+`
+
 func main() {
+	os.Exit(runMain(os.Args[1:]))
+}
+
+func runMain(arguments []string) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	expr := flags.String("e", "", "evaluate `expr` instead of reading a file")
+	engine := flags.String("engine", repl.EngineEval, "run code with the tree-walking `engine` \"eval\" or the bytecode \"vm\"")
+
+	if err := flags.Parse(arguments); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Fprintf(os.Stderr, "monkey: unknown engine %q\n", *engine)
+		return exitUsage
+	}
+
+	args := flags.Args()
+
+	if *expr != "" {
+		return execute("<expr>", *expr, args, *engine, true)
+	}
+
+	if len(args) > 0 && args[0] == "run" {
+		args = args[1:]
+		if len(args) == 0 {
+			fmt.Fprintln(os.Stderr, "monkey run: missing script file")
+			return exitUsage
+		}
+	}
+
+	if len(args) == 0 {
+		if isTerminal(os.Stdin) {
+			startRepl(*engine)
+			return exitOK
+		}
+		args = []string{"-"}
+	}
+
+	filename, scriptArgs := args[0], args[1:]
+
+	src, err := readSource(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return exitUsage
+	}
+
+	return execute(filename, src, scriptArgs, *engine, false)
+}
+
+func startRepl(engine string) {
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +95,29 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey Programming language!\n", user.Username)
 	fmt.Printf("Fell free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartEngine(os.Stdin, os.Stdout, engine)
+}
+
+func readSource(filename string) (string, error) {
+	var (
+		src []byte
+		err error
+	)
+
+	if filename == "-" {
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		src, err = ioutil.ReadFile(filename)
+	}
+
+	return string(src), err
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
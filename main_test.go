@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runWith calls runMain with arguments and input on stdin, and returns the
+// exit code with what was written to stdout and stderr.
+func runWith(t *testing.T, input string, arguments ...string) (code int, stdout, stderr string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	open := func(name, content string) *os.File {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	in, out, errOut := open("stdin", input), open("stdout", ""), open("stderr", "")
+	defer in.Close()
+	defer out.Close()
+	defer errOut.Close()
+
+	oldStdin, oldStdout, oldStderr := os.Stdin, os.Stdout, os.Stderr
+	os.Stdin, os.Stdout, os.Stderr = in, out, errOut
+	code = runMain(arguments)
+	os.Stdin, os.Stdout, os.Stderr = oldStdin, oldStdout, oldStderr
+
+	written, _ := ioutil.ReadFile(out.Name())
+	errWritten, _ := ioutil.ReadFile(errOut.Name())
+	return code, string(written), string(errWritten)
+}
+
+func writeScript(t *testing.T, dir, name, src string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunMain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ok := writeScript(t, dir, "ok.mk", "#!/usr/bin/env monkey\nif (len(args) != 2) { throw \"wrong args\" }")
+	failing := writeScript(t, dir, "failing.mk", "let x = 1;\nx + true")
+	broken := writeScript(t, dir, "broken.mk", "let x = ;")
+	self := writeScript(t, dir, "self.mk", "import \"./self\";")
+	missing := filepath.Join(dir, "missing.mk")
+
+	tests := []struct {
+		stdin     string
+		arguments []string
+		code      int
+		stdout    string
+		stderr    string // a part of what is written to stderr
+	}{
+		{"", []string{"run", ok, "a", "b"}, exitOK, "", ""},
+		{"", []string{ok, "a", "b"}, exitOK, "", ""},
+		{"", []string{"-engine=vm", "run", ok, "a", "b"}, exitOK, "", ""},
+		{"", []string{"run", ok}, exitRuntimeError, "", "wrong args"},
+		{"", []string{"run", failing}, exitRuntimeError, "", "type mismatch: INTEGER + BOOLEAN"},
+		{"", []string{"-engine=vm", failing}, exitRuntimeError, "", "type mismatch: INTEGER + BOOLEAN"},
+		{"", []string{"run", broken}, exitParseError, "", "let x = ;"},
+		{"", []string{"run", self}, exitRuntimeError, "", "import cycle"},
+		{"", []string{"-engine=vm", self}, exitRuntimeError, "", "import cycle"},
+		{"", []string{"run", missing}, exitUsage, "", "missing.mk"},
+		{"", []string{"run"}, exitUsage, "", "monkey run: missing script file"},
+		{"", []string{"-engine=jit", ok}, exitUsage, "", `unknown engine "jit"`},
+		{"", []string{"-unknown"}, exitUsage, "", "flag provided but not defined"},
+		{"", []string{"-e", "len(args)", "x", "y", "z"}, exitOK, "3\n", ""},
+		{"", []string{"-engine=vm", "-e", "args", "x", "y"}, exitOK, "[x, y]\n", ""},
+		{"", []string{"-e", "let x = 1;"}, exitOK, "", ""},
+		{"", []string{"-e", "1 +"}, exitParseError, "", ""},
+		{"", []string{"-e", "missing"}, exitRuntimeError, "", "identifier not found: missing"},
+		{"if (len(args) != 0) { throw 1 }", []string{}, exitOK, "", ""},
+		{"1 + true", []string{}, exitRuntimeError, "", "type mismatch: INTEGER + BOOLEAN"},
+		{"if ({\"a\": 1}[args[0]] != 1) { throw 1 }", []string{"-", "a"}, exitOK, "", ""},
+		{"let = 1", []string{"-"}, exitParseError, "", ""},
+	}
+
+	for _, tt := range tests {
+		code, stdout, stderr := runWith(t, tt.stdin, tt.arguments...)
+
+		if code != tt.code {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d (stderr %q)", tt.arguments, tt.code, code, stderr)
+		}
+		if stdout != tt.stdout {
+			t.Errorf("%v: wrong stdout. want=%q, got=%q", tt.arguments, tt.stdout, stdout)
+		}
+		if !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v: wrong stderr. want it to contain %q, got=%q", tt.arguments, tt.stderr, stderr)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+// Package module finds, loads and runs the files Monkey programs import.
+//
+// An import path starting with "./" or "../" is relative to the directory
+// of the importing file; any other relative path is looked up in each
+// directory of the search path in turn. Paths without an extension get
+// Extension appended. Every file is loaded and run at most once per
+// Loader, and importing it again, by whatever path, returns the same
+// module.
+package module
+
+import (
+	"fmt"
+	"io/ioutil"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extension is the extension of Monkey source files.
+const Extension = ".mk"
+
+// PathFromEnv returns the search path given by the MONKEYPATH environment
+// variable, a list of directories separated like PATH.
+func PathFromEnv() []string {
+	return filepath.SplitList(os.Getenv("MONKEYPATH"))
+}
+
+// Loader is an object.Importer reading modules from files. The zero value
+// runs modules on the evaluator and only resolves relative imports.
+type Loader struct {
+	// Path lists the directories searched for import paths that are not
+	// relative to the importing file.
+	Path []string
+	// Globals, if set, is the environment the modules run by the
+	// evaluator are enclosed in, such as one holding registered builtins.
+	// Its root also provides the budget the modules run with.
+	Globals *object.Environment
+	// VM runs modules on the bytecode vm instead of the evaluator. It must
+	// match the engine of the importing programs, as functions of one
+	// engine cannot be called by the other.
+	VM bool
+	// Confined restricts imports to the files inside the directories of
+	// Path and the directory of the main program, and rejects absolute
+	// import paths, so programs cannot read other files.
+	Confined bool
+
+	modules map[string]*module
+	// active lists the files being loaded or run, innermost last, to
+	// detect import cycles.
+	active []string
+}
+
+type module struct {
+	name string
+	file string
+
+	// program is the code of the module with its macros expanded.
+	program *ast.Program
+	// exports lists the names of the exported bindings that are not
+	// macros; macros holds the exported macros.
+	exports []string
+	macros  *object.Module
+
+	// value is set once the module ran.
+	value *object.Module
+}
+
+// Import implements object.Importer.
+func (l *Loader) Import(path string, at token.Span) object.Object {
+	m, err := l.load(path, at)
+	if err != nil {
+		return err
+	}
+
+	if m.value != nil {
+		return m.value
+	}
+
+	if err := l.enter(m.file, at); err != nil {
+		return err
+	}
+	defer l.leave()
+
+	value, err := l.run(m)
+	if err != nil {
+		// The module is dropped so a later import runs it again, which
+		// lets the REPL retry once the file is fixed.
+		delete(l.modules, m.file)
+		err.Stack = append(err.Stack, object.Frame{Function: "module " + m.name, Call: at})
+		return err
+	}
+
+	m.value = value
+	return value
+}
+
+// ImportMacros implements object.Importer.
+func (l *Loader) ImportMacros(path string, at token.Span) object.Object {
+	m, err := l.load(path, at)
+	if err != nil {
+		return err
+	}
+
+	return m.macros
+}
+
+// load parses the module at path, imported by the statement at at, and
+// expands its macros, unless that was done before.
+func (l *Loader) load(path string, at token.Span) (*module, *object.Error) {
+	file, err := l.resolve(path, at.Start.Filename)
+	if err != nil {
+		return nil, importError(path, at, err)
+	}
+
+	if m, ok := l.modules[file]; ok {
+		return m, nil
+	}
+
+	if err := l.enter(file, at); err != nil {
+		return nil, err
+	}
+	defer l.leave()
+
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, importError(path, at, err)
+	}
+
+	p := parser.New(lexer.NewFile(file, string(src)))
+	program := p.ParseProgram()
+	if errors := p.Errors(); len(errors) != 0 {
+		return nil, importError(path, at, errors[0])
+	}
+
+	m := &module{name: moduleName(file), file: file}
+	macros := map[string]object.Object{}
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+
+		if _, ok := export.Statement.Value.(*ast.MacroLiteral); ok && export.Statement.Name != nil {
+			macros[export.Statement.Name.Value] = nil
+			continue
+		}
+		for _, name := range export.Statement.Names() {
+			m.exports = append(m.exports, name.Value)
+		}
+	}
+
+	macroEnv := l.newEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	for name := range macros {
+		macros[name], _ = macroEnv.Get(name)
+	}
+
+	m.program = eval.ExpandMacros(program, macroEnv).(*ast.Program)
+	m.macros = &object.Module{Name: m.name, Path: file, Exports: macros}
+
+	if l.modules == nil {
+		l.modules = map[string]*module{}
+	}
+	l.modules[file] = m
+
+	return m, nil
+}
+
+// run runs the program of m and returns its exports.
+func (l *Loader) run(m *module) (*object.Module, *object.Error) {
+	var lookup func(name string) object.Object
+
+	if l.VM {
+		comp := compiler.New()
+		if err := comp.Compile(m.program); err != nil {
+			return nil, &object.Error{Message: fmt.Sprintf("%s: compile error: %s", m.file, err), Kind: object.ImportError}
+		}
+
+		machine := vm.New(comp.Bytecode())
+		machine.SetImporter(l)
+		if err, ok := machine.Run().(*object.Error); ok {
+			return nil, err
+		}
+
+		lookup = func(name string) object.Object {
+			symbol, _ := comp.SymbolTable().Resolve(name)
+			return machine.Globals()[symbol.Index]
+		}
+	} else {
+		env := l.newEnvironment()
+		if err, ok := eval.Eval(m.program, env).(*object.Error); ok {
+			return nil, err
+		}
+
+		lookup = func(name string) object.Object {
+			value, _ := env.Get(name)
+			return value
+		}
+	}
+
+	module := &object.Module{Name: m.name, Path: m.file, Exports: map[string]object.Object{}}
+	for name, macro := range m.macros.Exports {
+		module.Exports[name] = macro
+	}
+	for _, name := range m.exports {
+		module.Exports[name] = lookup(name)
+	}
+
+	return module, nil
+}
+
+// newEnvironment returns an environment for the code of a module, which
+// imports through l.
+func (l *Loader) newEnvironment() *object.Environment {
+	env := object.NewEnvironment()
+	if l.Globals != nil {
+		env = object.NewEnclosedEnvironment(l.Globals)
+	}
+	env.SetImporter(l)
+
+	return env
+}
+
+// resolve returns the file an import of path from the file named from
+// refers to. The file is absolute and free of symbolic links, so every
+// path leading to it names it the same way.
+func (l *Loader) resolve(path, from string) (string, error) {
+	name := filepath.FromSlash(path)
+	if filepath.Ext(name) == "" {
+		name += Extension
+	}
+
+	var candidates []string
+	switch {
+	case filepath.IsAbs(name):
+		if l.Confined {
+			return "", fmt.Errorf("absolute import paths are not allowed")
+		}
+		candidates = []string{name}
+	case strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../"):
+		candidates = []string{filepath.Join(filepath.Dir(from), name)}
+	default:
+		for _, dir := range l.Path {
+			candidates = append(candidates, filepath.Join(dir, name))
+		}
+	}
+
+	for _, file := range candidates {
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			file, err := canonical(file)
+			if err != nil || !l.Confined {
+				return file, err
+			}
+			return file, l.checkConfined(file, from)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("not found, the search path is empty")
+	}
+	return "", fmt.Errorf("not found in %s", strings.Join(candidates, ", "))
+}
+
+// checkConfined returns an error if file is outside the directories of
+// l.Path and of the main program. The main program is the outermost file
+// being run, or from if there is none.
+func (l *Loader) checkConfined(file, from string) error {
+	main := from
+	if len(l.active) > 0 {
+		main = l.active[0]
+	}
+
+	roots := append([]string{filepath.Dir(main)}, l.Path...)
+	for _, root := range roots {
+		root, err := canonical(root)
+		if err != nil {
+			continue
+		}
+
+		if rel, err := filepath.Rel(root, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is outside the module path", file)
+}
+
+// EnterMain marks the main program, read from file, as running until
+// leave is called, so that importing it is reported as a cycle instead of
+// running it a second time. It also makes file the main program Confined
+// is relative to. Names that are not files, such as "-" for stdin, are
+// ignored.
+func (l *Loader) EnterMain(file string) (leave func()) {
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() {
+		return func() {}
+	}
+	file, err = canonical(file)
+	if err != nil {
+		return func() {}
+	}
+
+	l.active = append(l.active, file)
+	return l.leave
+}
+
+// enter marks file as being loaded or run on behalf of the import at at,
+// or fails if it already is, which means it imports itself.
+func (l *Loader) enter(file string, at token.Span) *object.Error {
+	for i, active := range l.active {
+		if active == file {
+			cycle := append(append([]string{}, l.active[i:]...), file)
+			return &object.Error{
+				Message: "import cycle: " + strings.Join(cycle, " -> "),
+				Kind:    object.ImportError,
+				Span:    at,
+			}
+		}
+	}
+
+	l.active = append(l.active, file)
+	return nil
+}
+
+func (l *Loader) leave() {
+	l.active = l.active[:len(l.active)-1]
+}
+
+func canonical(file string) (string, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(file)
+}
+
+// moduleName returns the name of the module in file, which is the file name
+// without its extension.
+func moduleName(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+}
+
+func importError(path string, at token.Span, err error) *object.Error {
+	return &object.Error{
+		Message: fmt.Sprintf("cannot import %q: %s", path, err),
+		Kind:    object.ImportError,
+		Span:    at,
+	}
+}
@@ -0,0 +1,252 @@
+package module
+
+import (
+	"io/ioutil"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var files = map[string]string{
+	"lib/mathx.mk": `
+let secret = 42;
+let counter = 0;
+export let square = fn(x) { x * x };
+export let answer = fn() { secret };
+export let bump = fn() { counter += 1; counter };
+export let unless = macro(cond, cons, alt) {
+  quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) })
+};
+`,
+	"lib/twice.mk": `
+import "./mathx";
+export let twice = fn(x) { mathx["square"](x) * 2 };
+export let bump = mathx["bump"];
+`,
+	"cycle/a.mk":  `import "./b"; export let a = 1;`,
+	"cycle/b.mk":  `import "./a"; export let b = 2;`,
+	"self.mk":     `import "./self";`,
+	"broken.mk":   `let = 1;`,
+	"failing.mk":  "let f = fn() { 1 + true };\nexport let x = f();",
+	"dir.mk/x.mk": `1`,
+}
+
+func TestImport(t *testing.T) {
+	dir := writeFiles(t, files)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import m "./lib/mathx"; [m["square"](3), m["answer"]()]`, "[9, 42]"},
+		{`import "mathx"; mathx`, "<module mathx>"},
+		{`import "./lib/mathx.mk"; mathx["unless"](1 > 2, "less", "more")`, "less"},
+		{`import "./lib/twice"; twice["twice"](3)`, "18"},
+		{
+			`import a "./lib/mathx"; import b "mathx"; import "twice";
+			[a == b, a["bump"](), b["bump"](), twice["bump"]()]`,
+			"[true, 1, 2, 3]",
+		},
+		{
+			`let f = fn() { m["square"](4) }; import m "mathx"; f()`,
+			"16",
+		},
+	}
+
+	for _, engine := range []string{"eval", "vm"} {
+		for _, tt := range tests {
+			result := run(t, dir, engine, tt.input)
+			if result == nil || result.Inspect() != tt.expected {
+				t.Errorf("%s: %q: wrong result. want=%s, got=%v", engine, tt.input, tt.expected, result)
+			}
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := writeFiles(t, files)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "./lib/mathx"; mathx["secret"]`, "module mathx does not export secret"},
+		{`import "./lib/mathx"; mathx[1]`, "module mathx does not export 1"},
+		{`import "./missing"`, `cannot import "./missing": not found in $DIR/missing.mk`},
+		{`import "missing"`, `cannot import "missing": not found in $DIR/lib/missing.mk`},
+		{`import "./dir.mk"`, `cannot import "./dir.mk": not found in $DIR/dir.mk`},
+		{`import "./broken"`, `cannot import "./broken": $DIR/broken.mk:1:5: expected identifier, got = instead`},
+		{`import "./cycle/a"`, "import cycle: $DIR/cycle/a.mk -> $DIR/cycle/b.mk -> $DIR/cycle/a.mk"},
+		{`import "./self"`, "import cycle: $DIR/self.mk -> $DIR/self.mk"},
+		{`import "./failing"`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, engine := range []string{"eval", "vm"} {
+		for _, tt := range tests {
+			expected := strings.ReplaceAll(tt.expected, "$DIR", dir)
+
+			err, ok := run(t, dir, engine, tt.input).(*object.Error)
+			if !ok {
+				t.Errorf("%s: %q: expected an error", engine, tt.input)
+				continue
+			}
+			if err.Message != expected {
+				t.Errorf("%s: %q: wrong error. want=%q, got=%q", engine, tt.input, expected, err.Message)
+			}
+		}
+	}
+}
+
+func TestImportErrorStack(t *testing.T) {
+	dir := writeFiles(t, files)
+	defer os.RemoveAll(dir)
+
+	for _, engine := range []string{"eval", "vm"} {
+		err := run(t, dir, engine, `1;
+import "./failing"`).(*object.Error)
+
+		if err.Span.Start.Filename != filepath.Join(dir, "failing.mk") || err.Span.Start.Line != 1 {
+			t.Errorf("%s: wrong error position. got=%s", engine, err.Span.Start)
+		}
+
+		if len(err.Stack) != 2 || err.Stack[0].Function != "f" || err.Stack[1].Function != "module failing" {
+			t.Fatalf("%s: wrong stack. got=%+v", engine, err.Stack)
+		}
+		if call := err.Stack[1].Call.Start; call.Filename != filepath.Join(dir, "main.mk") || call.Line != 2 {
+			t.Errorf("%s: wrong import position. got=%s", engine, call)
+		}
+	}
+}
+
+func TestImportSameFileByDifferentPaths(t *testing.T) {
+	dir := writeFiles(t, files)
+	defer os.RemoveAll(dir)
+
+	if err := os.Symlink(filepath.Join(dir, "lib"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative imports from the REPL are resolved against the working
+	// directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, engine := range []string{"eval", "vm"} {
+		loader := &Loader{Path: []string{filepath.Join(dir, "lib")}, VM: engine == "vm"}
+		at := token.Span{Start: token.Position{Filename: "main.mk", Line: 1, Column: 1}}
+
+		first := loader.Import("./lib/mathx", at)
+		if _, ok := first.(*object.Module); !ok {
+			t.Fatalf("%s: expected a module. got=%v", engine, first)
+		}
+		for _, path := range []string{"mathx", "./link/mathx", filepath.Join(dir, "lib", "..", "lib", "mathx.mk")} {
+			if module := loader.Import(path, at); module != first {
+				t.Errorf("%s: %q was loaded again. got=%v", engine, path, module)
+			}
+		}
+	}
+}
+
+func TestEnterMain(t *testing.T) {
+	dir := writeFiles(t, files)
+	defer os.RemoveAll(dir)
+
+	loader := &Loader{}
+	a := filepath.Join(dir, "cycle", "a.mk")
+	at := token.Span{Start: token.Position{Filename: a, Line: 1, Column: 1}}
+
+	leave := loader.EnterMain(a)
+	err, ok := loader.Import("./b", at).(*object.Error)
+	expected := strings.ReplaceAll("import cycle: $DIR/cycle/a.mk -> $DIR/cycle/b.mk -> $DIR/cycle/a.mk", "$DIR", dir)
+	if !ok || err.Message != expected {
+		t.Errorf("wrong result. want=%q, got=%v", expected, err)
+	}
+	leave()
+
+	if len(loader.active) != 0 {
+		t.Errorf("the main program is still active: %v", loader.active)
+	}
+
+	// Names that are not files leave the loader as it was.
+	loader.EnterMain("-")()
+	loader.EnterMain(dir)()
+	if len(loader.active) != 0 {
+		t.Errorf("wrong active files: %v", loader.active)
+	}
+}
+
+// run runs input as the file main.mk in dir with the given engine, with
+// dir/lib as the search path.
+func run(t *testing.T, dir, engine, input string) object.Object {
+	t.Helper()
+
+	p := parser.New(lexer.NewFile(filepath.Join(dir, "main.mk"), input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parse errors: %v", input, p.Errors())
+	}
+
+	loader := &Loader{Path: []string{filepath.Join(dir, "lib")}, VM: engine == "vm"}
+
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetImporter(loader)
+	eval.DefineMacros(program, macroEnv)
+	expanded := eval.ExpandMacros(program, macroEnv)
+
+	if engine == "vm" {
+		comp := compiler.New()
+		if err := comp.Compile(expanded); err != nil {
+			t.Fatalf("%q: compiler error: %s", input, err)
+		}
+
+		machine := vm.New(comp.Bytecode())
+		machine.SetImporter(loader)
+		return machine.Run()
+	}
+
+	env := object.NewEnvironment()
+	env.SetImporter(loader)
+	return eval.Eval(expanded, env)
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "monkey-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The loader names files by their real path, so the directory must not
+	// be reached through a symbolic link for the paths in errors to match.
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, src := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
@@ -2,9 +2,15 @@ package object
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
+	"monkey/code"
+	"monkey/token"
+	"strconv"
 	"strings"
 )
 
@@ -17,22 +23,30 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	STRING_OBJ       = "STRING"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	FUNCTION_OBJ     = "FUNCTION"
 	ERROR_OBJ        = "ERROR"
+	EXCEPTION_OBJ    = "EXCEPTION"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	QUOTE_OBJ        = "QUOTE"
 	MACRO_OBJ        = "MACRO"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.root = outer.Root()
 	return env
 }
 
@@ -44,6 +58,95 @@ func NewEnvironment() *Environment {
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	root     *Environment // the outermost environment, nil if this is it
+	budget   *Budget      // only set on the root
+	importer Importer     // only set on the root
+}
+
+// Root returns the outermost environment e is enclosed in, or e itself.
+func (e *Environment) Root() *Environment {
+	if e.root == nil {
+		return e
+	}
+	return e.root
+}
+
+// Budget returns the budget of the evaluation running in e, or nil.
+func (e *Environment) Budget() *Budget {
+	return e.Root().budget
+}
+
+// SetBudget attaches b to the root of e, so it applies to everything
+// evaluated in e or in environments enclosed in it, including closures.
+func (e *Environment) SetBudget(b *Budget) {
+	e.Root().budget = b
+}
+
+// Importer returns the importer that loads the modules imported by code
+// evaluated in e, or nil if it may not import any.
+func (e *Environment) Importer() Importer {
+	return e.Root().importer
+}
+
+// SetImporter attaches importer to the root of e, like SetBudget.
+func (e *Environment) SetImporter(importer Importer) {
+	e.Root().importer = importer
+}
+
+// Importer loads the modules a program imports. at is the span of the
+// import statement, which also tells the file the path is relative to.
+type Importer interface {
+	// Import returns the *Module at path, running it unless it already
+	// ran, or an *Error if it cannot be found, does not parse or fails.
+	Import(path string, at token.Span) Object
+	// ImportMacros returns a *Module holding only the macros exported by
+	// the module at path, without running it, or an *Error.
+	ImportMacros(path string, at token.Span) Object
+}
+
+// Budget bounds the resources an evaluation may use and counts what it has
+// used so far. Zero limits mean no limit.
+type Budget struct {
+	Context      context.Context // the evaluation stops once it is done
+	MaxSteps     int64
+	MaxCallDepth int
+	MaxAllocated int64 // bytes, as estimated by SizeOf
+
+	Steps     int64 // nodes evaluated so far
+	Depth     int   // Monkey function calls currently active
+	Allocated int64 // bytes allocated for new strings, arrays, hashes and big integers
+}
+
+// Approximate sizes in bytes of the parts of objects, for SizeOf.
+const (
+	objectSize    = 16 // the struct and its allocation header
+	sliceSize     = 24
+	stringSize    = 16
+	interfaceSize = 16
+	wordSize      = 8
+)
+
+// HashPairSize is the approximate bytes each entry of a hash occupies: its
+// key, its pair and the overhead of the map bucket.
+const HashPairSize = 64
+
+// SizeOf estimates the bytes obj occupies, not counting the objects it
+// refers to. Only strings, arrays, hashes and big integers are counted;
+// other objects are reported as 0.
+func SizeOf(obj Object) int64 {
+	switch obj := obj.(type) {
+	case *String:
+		return objectSize + stringSize + int64(len(obj.Value))
+	case *Array:
+		return objectSize + sliceSize + interfaceSize*int64(len(obj.Elements))
+	case *Hash:
+		return objectSize + HashPairSize*int64(len(obj.Pairs))
+	case *BigInteger:
+		return objectSize + sliceSize + wordSize*int64(len(obj.Value.Bits()))
+	default:
+		return 0
+	}
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -58,6 +161,19 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign updates name in the innermost environment that binds it, so the
+// change is seen by every closure sharing that environment. It reports
+// false if name is not bound at all.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
+
 type ReturnValue struct {
 	Value Object
 }
@@ -69,8 +185,22 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Break and Continue unwind the statements of a loop body up to the loop
+// that runs it, like ReturnValue does for a function body.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Function struct {
-	Parameters []*ast.Identifier
+	Name       string // the let binding the literal was assigned to, if any
+	Parameters []ast.Pattern
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -85,6 +215,9 @@ func (f *Function) Inspect() string {
 	for _, p := range f.Parameters {
 		params = append(params, p.String())
 	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
 
 	out.WriteString("fn")
 	out.WriteString("(")
@@ -98,8 +231,59 @@ func (f *Function) Inspect() string {
 
 type Error struct {
 	Message string
+	Kind    ErrorKind
+	Span    token.Span // where the error was raised
+	Stack   []Frame    // Monkey calls the error unwound, innermost first
+
+	// Value is the value a throw statement raised, nil for errors raised
+	// by the interpreter.
+	Value Object
+
+	// Limit is set when the error stopped an evaluation that exceeded its
+	// budget, rather than being raised by the program. Such errors cannot
+	// be caught.
+	Limit LimitKind
 }
 
+// ErrorKind tells what kind of problem an error reports, so programs that
+// catch errors can tell them apart.
+type ErrorKind string
+
+const (
+	GenericError    ErrorKind = "error"
+	TypeError       ErrorKind = "type"       // an operation on values that do not support it
+	NameError       ErrorKind = "name"       // an identifier, export or field that does not exist
+	ArgumentError   ErrorKind = "argument"   // a call with the wrong number or kind of arguments
+	IndexError      ErrorKind = "index"      // an index out of range
+	ArithmeticError ErrorKind = "arithmetic" // division by zero or a result too large
+	ImportError     ErrorKind = "import"     // a module that cannot be loaded
+	LimitError      ErrorKind = "limit"      // an exceeded limit, see Error.Limit
+	ThrownError     ErrorKind = "thrown"     // a value raised by a throw statement
+	MatchError      ErrorKind = "match"      // a match expression without an arm for its value
+)
+
+// LimitKind tells which limit of a Budget an evaluation exceeded.
+type LimitKind string
+
+const (
+	StepLimit      LimitKind = "steps"
+	CallDepthLimit LimitKind = "call depth"
+	DeadlineLimit  LimitKind = "deadline"
+	CanceledLimit  LimitKind = "canceled"
+	MemoryLimit    LimitKind = "memory"
+)
+
+// Frame is a call to a Monkey function that was active when an error was
+// raised.
+type Frame struct {
+	Function string     // empty for anonymous functions
+	Call     token.Span // the call expression
+}
+
+// maxTracebackFrames limits how many frames Traceback prints; the middle of
+// longer stacks, usually deep recursion, is elided.
+const maxTracebackFrames = 20
+
 func (e *Error) Type() ObjectType {
 	return ERROR_OBJ
 }
@@ -107,6 +291,57 @@ func (e *Error) Inspect() string {
 	return "Error: " + e.Message
 }
 
+// Traceback renders the error with the Monkey calls leading to it, the
+// outermost call first.
+func (e *Error) Traceback() string {
+	if !e.Span.IsValid() && len(e.Stack) == 0 {
+		return e.Inspect()
+	}
+
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		elided := len(e.Stack) > maxTracebackFrames &&
+			i >= maxTracebackFrames/2 && i < len(e.Stack)-maxTracebackFrames/2
+		if elided {
+			if i == maxTracebackFrames/2 {
+				fmt.Fprintf(&out, "  ... %d more calls\n", len(e.Stack)-maxTracebackFrames)
+			}
+			continue
+		}
+
+		// Calls made from Go, such as those of Apply, have no position.
+		if !e.Stack[i].Call.IsValid() {
+			continue
+		}
+
+		caller := "<main>"
+		if i+1 < len(e.Stack) {
+			caller = frameName(e.Stack[i+1].Function)
+		}
+		fmt.Fprintf(&out, "  at %s in %s\n", e.Stack[i].Call.Start, caller)
+	}
+
+	current := "<main>"
+	if len(e.Stack) > 0 {
+		current = frameName(e.Stack[0].Function)
+	}
+	fmt.Fprintf(&out, "  at %s in %s\n", e.Span.Start, current)
+
+	out.WriteString(e.Inspect())
+
+	return out.String()
+}
+
+func frameName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
 type Integer struct {
 	Value int64
 }
@@ -118,6 +353,41 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// BigInteger is an integer that does not fit in an int64. Integer
+// arithmetic promotes its results to BigInteger when they overflow and
+// demotes them back to Integer when they fit again, so a BigInteger always
+// holds a value outside the int64 range. It reports INTEGER_OBJ as its type
+// since programs cannot tell the two apart.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (i *BigInteger) Type() ObjectType {
+	return INTEGER_OBJ
+}
+func (i *BigInteger) Inspect() string {
+	return i.Value.String()
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect formats f in the shortest form that reads back as the same
+// value, keeping a fraction on whole numbers so 2.0 does not look like the
+// integer 2.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -168,18 +438,7 @@ type Array struct {
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ }
 func (ao *Array) Inspect() string {
-	var out bytes.Buffer
-
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
-	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
-	return out.String()
+	return inspect(ao, map[Object]bool{})
 }
 
 type Hashable interface {
@@ -193,19 +452,56 @@ type Hash struct {
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
 func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+	return inspect(h, map[Object]bool{})
+}
+
+// inspect renders obj like its Inspect method. Index assignment can store
+// an array or hash inside itself, so the ones currently being rendered
+// are tracked in inside, and one that repeats is printed as [...] or {...}.
+func inspect(obj Object, inside map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if inside[obj] {
+			return "[...]"
+		}
+		inside[obj] = true
+		defer delete(inside, obj)
+
+		var out bytes.Buffer
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, inside))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+
+		return out.String()
+	case *Hash:
+		if inside[obj] {
+			return "{...}"
+		}
+		inside[obj] = true
+		defer delete(inside, obj)
+
+		var out bytes.Buffer
+
+		pairs := []string{}
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, inside), inspect(pair.Value, inside)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+
+		return out.String()
+	default:
+		return obj.Inspect()
 	}
-
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-
-	return out.String()
 }
 
 type HashPair struct {
@@ -244,6 +540,35 @@ func (i *Integer) HashKey() HashKey {
 	}
 }
 
+// HashKey uses the bits of the value, so 0.0 and -0.0, which compare
+// equal, are folded together first. Floats and integers are distinct keys
+// even when their values compare equal.
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		value = 0
+	}
+
+	return HashKey{
+		Type:  f.Type(),
+		Value: math.Float64bits(value),
+	}
+}
+
+// bigIntegerKey is the HashKey type of big integers. It keeps their hashes
+// apart from those of small integers, which never have the same value.
+const bigIntegerKey ObjectType = "BIG_INTEGER"
+
+func (i *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	if i.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(i.Value.Bytes())
+
+	return HashKey{Type: bigIntegerKey, Value: h.Sum64()}
+}
+
 type Quote struct {
 	Node ast.Node
 }
@@ -257,7 +582,8 @@ func (q *Quote) Inspect() string {
 }
 
 type Macro struct {
-	Parameters []*ast.Identifier
+	Parameters []ast.Pattern
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -270,6 +596,9 @@ func (m *Macro) Inspect() string {
 	for _, p := range m.Parameters {
 		params = append(params, p.String())
 	}
+	if m.Rest != nil {
+		params = append(params, "..."+m.Rest.String())
+	}
 
 	out.WriteString("macro")
 	out.WriteString("(")
@@ -280,3 +609,82 @@ func (m *Macro) Inspect() string {
 
 	return out.String()
 }
+
+// Exception is an error caught by a catch clause. Unlike an Error it is an
+// ordinary value: it does not abort the evaluation, and throwing it raises
+// Err again.
+type Exception struct {
+	Err *Error
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string  { return e.Err.Inspect() }
+
+// Module is an imported file. Exports holds the values of the bindings it
+// exported, which programs read by indexing the module with their names.
+type Module struct {
+	Name    string
+	Path    string // the file the module was loaded from
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "<module " + m.Name + ">" }
+
+// CompiledFunction is a function literal lowered to bytecode by the
+// compiler. It only ever appears in the constant pool; at runtime it is
+// wrapped in a Closure.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	// NumRequired is the number of arguments a call must pass at least;
+	// the parameters after them have defaults.
+	NumRequired int
+	// Variadic is set if the function collects the arguments after its
+	// parameters in an array, kept in the local slot after them.
+	Variadic bool
+	Name     string
+
+	// Positions maps instruction offsets to the node they were compiled
+	// from, for error reporting.
+	Positions map[int]token.Span
+	// LocalNames holds the name of each local slot, and FreeNames that of
+	// each free variable.
+	LocalNames []string
+	FreeNames  []string
+	// Literal is the source of the function, used by Inspect.
+	Literal *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Literal == nil {
+		return fmt.Sprintf("CompiledFunction[%p]", cf)
+	}
+
+	fn := &Function{Parameters: cf.Literal.Parameters, Rest: cf.Literal.Rest, Body: cf.Literal.Body}
+	return fn.Inspect()
+}
+
+// Closure is a compiled function together with the free variables it
+// captured. To Monkey code it is an ordinary function.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+	// Unit is the program the closure was created by. The constants and
+	// globals Fn refers to are those of Unit, which is not the running
+	// program when the closure was imported from a module.
+	Unit *Unit
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
+
+// Unit is the state of a compiled program shared by all of its functions:
+// its constant pool and global slots, and the names of the globals.
+type Unit struct {
+	Constants   []Object
+	Globals     []Object
+	GlobalNames []string
+}
@@ -1,6 +1,11 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"monkey/token"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +63,95 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("boolean with different content have same hash keys")
 	}
 }
+
+func TestBigIntegerHashKey(t *testing.T) {
+	parse := func(s string) *BigInteger {
+		n, _ := new(big.Int).SetString(s, 10)
+		return &BigInteger{Value: n}
+	}
+	big1 := parse("99999999999999999999")
+	big2 := parse("99999999999999999999")
+	negative := parse("-99999999999999999999")
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with same content have different hash keys")
+	}
+
+	if big1.HashKey() == negative.HashKey() {
+		t.Errorf("big integers with different signs have same hash keys")
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	zero := &Float{Value: 0}
+	negativeZero := &Float{Value: math.Copysign(0, -1)}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if zero.HashKey() != negativeZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	if half1.HashKey() == zero.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+
+	if (&Float{Value: 1}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float and integer have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-100000, "-100000.0"},
+		{1e21, "1e+21"},
+		{0.30000000000000004, "0.30000000000000004"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("wrong output for %g. want=%q, got=%q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	pos := func(line, column int) token.Span {
+		return token.Span{Start: token.Position{Filename: "a.mk", Line: line, Column: column}}
+	}
+
+	err := &Error{
+		Message: "identifier not found: y",
+		Span:    pos(2, 7),
+		Stack: []Frame{
+			{Function: "", Call: pos(5, 3)},
+			{Function: "outer", Call: pos(7, 14)},
+		},
+	}
+
+	expected := `Traceback (most recent call last):
+  at a.mk:7:14 in <main>
+  at a.mk:5:3 in outer
+  at a.mk:2:7 in <anonymous>
+Error: identifier not found: y`
+
+	if err.Traceback() != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, err.Traceback())
+	}
+
+	plain := &Error{Message: "boom"}
+	if plain.Traceback() != "Error: boom" {
+		t.Errorf("wrong traceback without position. got=%q", plain.Traceback())
+	}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+// ErrorCode identifies the kind of a ParseError.
+type ErrorCode int
+
+const (
+	_ ErrorCode = iota
+	ErrUnexpectedToken
+	ErrNoPrefixParseFn
+	ErrInvalidInteger
+	ErrIllegalCharacter
+	ErrInvalidFloat
+	ErrInvalidToken
+	ErrOutsideLoop
+	ErrInvalidAssignment
+	ErrNotTopLevel
+	ErrInvalidImport
+	ErrInvalidPattern
+)
+
+func (c ErrorCode) String() string {
+	return fmt.Sprintf("E%03d", int(c))
+}
+
+// ParseError describes a syntax error found by the parser.
+type ParseError struct {
+	Code    ErrorCode
+	Span    token.Span
+	Message string
+
+	// Expected is the token type the parser was looking for. It is empty
+	// when the error is not about a missing token.
+	Expected token.TokenType
+	// Actual is the token found at the error position.
+	Actual token.Token
+}
+
+func (e *ParseError) Error() string {
+	if !e.Span.IsValid() {
+		return e.Message
+	}
+
+	return e.Span.Start.String() + ": " + e.Message
+}
+
+// describe returns a readable name for a token in error messages.
+func describe(t token.Token) string {
+	switch t.Type {
+	case token.IDENT, token.STRING, token.ILLEGAL:
+		return fmt.Sprintf("%s %q", describeType(t.Type), t.Literal)
+	case token.INT, token.FLOAT:
+		return describeType(t.Type) + " " + t.Literal
+	default:
+		return describeType(t.Type)
+	}
+}
+
+// describeType returns a readable name for a kind of token: what is
+// written for keywords and punctuation, and what the others stand for.
+func describeType(t token.TokenType) string {
+	switch t {
+	case token.EOF:
+		return "end of input"
+	case token.ILLEGAL:
+		return "illegal character"
+	case token.IDENT:
+		return "identifier"
+	case token.INT:
+		return "integer"
+	case token.FLOAT:
+		return "float"
+	case token.STRING, token.STRING_HEAD:
+		return "string"
+	case token.STRING_MIDDLE, token.STRING_TAIL:
+		// The end of an interpolation, as in "${x} b".
+		return "}"
+	case token.FUNCTION:
+		return "fn"
+	}
+
+	if keyword := strings.ToLower(string(t)); token.LookupIdent(keyword) == t {
+		return keyword
+	}
+	return string(t)
+}
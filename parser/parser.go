@@ -1,11 +1,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
@@ -13,7 +16,19 @@ type Parser struct {
 
 	curToken  token.Token
 	peekToken token.Token
-	errors    []string
+	errors    []*ParseError
+
+	// recovering is set after an error until the parser has skipped to
+	// the next statement, so one mistake is only reported once.
+	recovering bool
+	// brackets lists the brackets left open up to and including
+	// curToken, innermost last.
+	brackets []token.TokenType
+	// lexerErrors counts the lexer errors already reported.
+	lexerErrors int
+	// loops counts the loops enclosing curToken within the current
+	// function, so break and continue can be rejected outside of one.
+	loops int
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	InfixParseFns  map[token.TokenType]infixParseFn
@@ -28,34 +43,49 @@ type (
 const (
 	_ int = iota
 	LOWEST
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
+	SUM         // + - | ^
+	PRODUCT     // * / % << >> &
 	PREFIX      // -X or !X
+	POWER       // **
 	CALL        // myFunction(X)
 	INDEX       // index
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NEQ:      EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.EQ:          EQUALS,
+	token.NEQ:         EQUALS,
+	token.OR:          OR,
+	token.AND:         AND,
+	token.LT:          LESSGREATER,
+	token.GT:          LESSGREATER,
+	token.LT_EQ:       LESSGREATER,
+	token.GT_EQ:       LESSGREATER,
+	token.PLUS:        SUM,
+	token.MINUS:       SUM,
+	token.PIPE:        SUM,
+	token.CARET:       SUM,
+	token.SLASH:       PRODUCT,
+	token.ASTERISK:    PRODUCT,
+	token.PERCENT:     PRODUCT,
+	token.SHIFT_LEFT:  PRODUCT,
+	token.SHIFT_RIGHT: PRODUCT,
+	token.AMPERSAND:   PRODUCT,
+	token.POWER:       POWER,
+	token.LPAREN:      CALL,
+	token.LBRACKET:    INDEX,
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*ParseError{}}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.BANG, p.parsePrefixOperator)
@@ -64,9 +94,13 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
 
 	p.InfixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -77,6 +111,17 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
@@ -87,19 +132,102 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+func (p *Parser) addError(err *ParseError) {
+	if p.recovering {
+		return
+	}
+
+	p.errors = append(p.errors, err)
+	p.recovering = true
+}
+
+// reportLexerErrors adds the errors the lexer found since the last call.
+// They bypass addError: they are not caused by the statement being parsed,
+// so they must neither be suppressed by nor start error recovery.
+func (p *Parser) reportLexerErrors() {
+	errors := p.l.Errors()
+	for ; p.lexerErrors < len(errors); p.lexerErrors++ {
+		err := errors[p.lexerErrors]
+		p.errors = append(p.errors, &ParseError{
+			Code:    ErrInvalidToken,
+			Span:    err.Span,
+			Message: err.Message,
+		})
+	}
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	msg := fmt.Sprintf("expected %s, got %s instead",
+		describeType(t), describe(p.peekToken))
+	p.addError(&ParseError{
+		Code:     ErrUnexpectedToken,
+		Span:     p.peekToken.Span,
+		Message:  msg,
+		Expected: t,
+		Actual:   p.peekToken,
+	})
+}
+
+// synchronize skips the rest of a broken statement at the given nesting
+// level. It stops on the statement's semicolon, before the next let,
+// return, throw, loop, import or export, or before the '}' closing the enclosing block.
+//
+// Statements cannot appear directly inside parentheses or brackets, so one
+// starting there means they were never closed: they are dropped and
+// recovery stops before the statement, as if they had been.
+func (p *Parser) synchronize(level int) {
+	p.recovering = false
+
+	for p.nesting() >= level {
+		if p.nesting() == level && p.curTokenIs(token.SEMICOLON) {
+			return
+		}
+
+		if p.peekTokenIs(token.EOF) {
+			return
+		}
+
+		switch p.peekToken.Type {
+		case token.LET, token.RETURN, token.THROW, token.WHILE, token.FOR, token.IMPORT, token.EXPORT:
+			for p.nesting() > level && p.brackets[p.nesting()-1] != token.LBRACE {
+				p.brackets = p.brackets[:p.nesting()-1]
+			}
+			if p.nesting() == level {
+				return
+			}
+		case token.RBRACE:
+			if p.nesting() == level && level > 0 {
+				return
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
+// nesting returns the number of brackets left open up to and including
+// curToken.
+func (p *Parser) nesting() int {
+	return len(p.brackets)
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.reportLexerErrors()
+
+	switch p.curToken.Type {
+	case token.LPAREN, token.LBRACE, token.LBRACKET:
+		p.brackets = append(p.brackets, p.curToken.Type)
+	case token.RPAREN, token.RBRACE, token.RBRACKET:
+		if p.nesting() > 0 {
+			p.brackets = p.brackets[:p.nesting()-1]
+		}
+	}
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -150,7 +278,11 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		program.Statements = append(program.Statements, stmt)
+		if p.recovering {
+			p.synchronize(0)
+		} else if stmt != nil {
+			program.Statements = append(program.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
@@ -163,18 +295,35 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControl()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
-	if !p.expectPeek(token.IDENT) {
+func (p *Parser) parseLetStatement() ast.Statement {
+	stmt := &ast.LetStatement{Token: p.curToken, Doc: p.curToken.Doc}
+
+	target := p.parseBinding()
+	if target == nil {
 		return nil
 	}
-
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if name, ok := target.(*ast.Identifier); ok {
+		stmt.Name = name
+	} else {
+		stmt.Pattern = target
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -184,6 +333,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fl.Name = stmt.Name.Value
+	}
+
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -205,11 +358,258 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopBody parses the block of a loop, in which break and continue
+// are allowed.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControl() ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
+	if p.loops == 0 {
+		p.addError(&ParseError{
+			Code:    ErrOutsideLoop,
+			Span:    p.curToken.Span,
+			Message: fmt.Sprintf("%s outside of a loop", p.curToken.Literal),
+			Actual:  p.curToken,
+		})
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if !p.expectTopLevel() {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if stmt.Name == nil {
+		name, ok := moduleName(stmt.Path.Value)
+		if !ok {
+			p.addError(&ParseError{
+				Code:    ErrInvalidImport,
+				Span:    p.curToken.Span,
+				Message: fmt.Sprintf("cannot derive a name from import path %q, use import name %q", stmt.Path.Value, stmt.Path.Value),
+				Actual:  p.curToken,
+			})
+			return nil
+		}
+
+		nameToken := p.curToken
+		nameToken.Type = token.IDENT
+		nameToken.Literal = name
+		stmt.Name = &ast.Identifier{Token: nameToken, Value: name}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// moduleName derives the name an import binds from the last element of its
+// path without the extension, and reports whether that is an identifier.
+func moduleName(path string) (string, bool) {
+	name := path[strings.LastIndex(path, "/")+1:]
+	if dot := strings.LastIndex(name, "."); dot > 0 {
+		name = name[:dot]
+	}
+
+	l := lexer.New(name)
+	tok := l.NextToken()
+	return name, tok.Type == token.IDENT && tok.Literal == name && l.NextToken().Type == token.EOF
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if !p.expectTopLevel() || !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	let, ok := p.parseLetStatement().(*ast.LetStatement)
+	if !ok {
+		return nil
+	}
+	if let.Doc == "" {
+		let.Doc = stmt.Token.Doc
+	}
+	stmt.Statement = let
+
+	return stmt
+}
+
+// expectTopLevel reports whether curToken is at the top level of the
+// program, outside of any block, and adds an error if it is not.
+func (p *Parser) expectTopLevel() bool {
+	if p.nesting() == 0 {
+		return true
+	}
+
+	p.addError(&ParseError{
+		Code:    ErrNotTopLevel,
+		Span:    p.curToken.Span,
+		Message: fmt.Sprintf("%s is only allowed at the top level", p.curToken.Literal),
+		Actual:  p.curToken,
+	})
+	return false
+}
+
+// assignOperators are the tokens that turn an expression statement into an
+// assignment to the expression.
+var assignOperators = map[token.TokenType]bool{
+	token.ASSIGN:          true,
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+}
+
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if assignOperators[p.peekToken.Type] {
+		p.nextToken()
+		return p.parseAssignStatement(stmt.Expression)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	stmt := &ast.AssignStatement{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		if target != nil {
+			p.addError(&ParseError{
+				Code:    ErrInvalidAssignment,
+				Span:    target.Span(),
+				Message: fmt.Sprintf("cannot assign to %s", target.String()),
+				Actual:  p.curToken,
+			})
+		}
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -220,7 +620,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefiXParseFnError(p.curToken.Type)
+		p.noPrefiXParseFnError(p.curToken)
 		return nil
 	}
 
@@ -240,30 +640,127 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) noPrefiXParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function found for %s", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefiXParseFnError(t token.Token) {
+	if t.Type == token.ILLEGAL {
+		p.addError(&ParseError{
+			Code:    ErrIllegalCharacter,
+			Span:    t.Span,
+			Message: fmt.Sprintf("illegal character %q", t.Literal),
+			Actual:  t,
+		})
+		return
+	}
+
+	// The end of an interpolation, where the expression it holds is
+	// incomplete, as in "${1 + }".
+	if t.Type == token.STRING_MIDDLE || t.Type == token.STRING_TAIL {
+		p.addError(&ParseError{
+			Code:    ErrNoPrefixParseFn,
+			Span:    t.Span,
+			Message: "expected expression in ${...}, got }",
+			Actual:  t,
+		})
+		return
+	}
+
+	p.addError(&ParseError{
+		Code:    ErrNoPrefixParseFn,
+		Span:    t.Span,
+		Message: fmt.Sprintf("expected expression, got %s instead", describe(t)),
+		Actual:  t,
+	})
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseIntegerLiteral parses literals too large for an int64 into
+// IntegerLiteral.Big.
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.IntegerLiteral{Token: p.curToken, Big: n}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&ParseError{
+			Code:    ErrInvalidInteger,
+			Span:    p.curToken.Span,
+			Message: msg,
+			Actual:  p.curToken,
+		})
 		return nil
 	}
 
 	return &ast.IntegerLiteral{Token: p.curToken, Value: value}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(&ParseError{
+			Code:    ErrInvalidFloat,
+			Span:    p.curToken.Span,
+			Message: msg,
+			Actual:  p.curToken,
+		})
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+
+	for {
+		if p.curToken.Literal != "" {
+			text := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+			str.Parts = append(str.Parts, text)
+		}
+		if p.curTokenIs(token.STRING_TAIL) {
+			str.Tail = p.curToken
+			return str
+		}
+
+		p.nextToken()
+		if p.curTokenIs(token.STRING_MIDDLE) || p.curTokenIs(token.STRING_TAIL) {
+			p.addError(&ParseError{
+				Code:    ErrNoPrefixParseFn,
+				Span:    p.curToken.Span,
+				Message: "empty interpolation, expected expression in ${...}",
+				Actual:  p.curToken,
+			})
+			continue
+		}
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.STRING_MIDDLE) && !p.peekTokenIs(token.STRING_TAIL) {
+			p.addError(&ParseError{
+				Code:     ErrUnexpectedToken,
+				Span:     p.peekToken.Span,
+				Message:  fmt.Sprintf("expected } to close interpolation, got %s instead", describe(p.peekToken)),
+				Expected: token.STRING_TAIL,
+				Actual:   p.peekToken,
+			})
+			return nil
+		}
+		p.nextToken()
+	}
+}
+
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -282,6 +779,10 @@ func (p *Parser) parsePrefixOperator() ast.Expression {
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	exp := &ast.InfixExpression{Token: p.curToken, Operator: p.curToken.Literal, Left: left}
 	precedence := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// ** is right-associative: 2 ** 3 ** 2 is 2 ** (3 ** 2).
+		precedence--
+	}
 	p.nextToken()
 
 	right := p.parseExpression(precedence)
@@ -325,6 +826,15 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
 
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			exp.Alternative = p.parseElseIf()
+			if exp.Alternative == nil {
+				return nil
+			}
+			return exp
+		}
+
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -336,18 +846,111 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 }
 
+// parseElseIf parses the if after an else into a block that holds only
+// that if expression.
+func (p *Parser) parseElseIf() *ast.BlockStatement {
+	ifToken := p.curToken
+
+	nested := p.parseIfExpression()
+	if nested == nil {
+		return nil
+	}
+
+	return &ast.BlockStatement{
+		Token: ifToken,
+		Statements: []ast.Statement{
+			&ast.ExpressionStatement{Token: ifToken, Expression: nested},
+		},
+		Rbrace: p.curToken,
+	}
+}
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		exp.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Finally = p.parseBlockStatement()
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		p.addError(&ParseError{
+			Code: ErrUnexpectedToken,
+			Span: p.peekToken.Span,
+			Message: fmt.Sprintf("expected catch or finally after try block, got %s instead",
+				describe(p.peekToken)),
+			Expected: token.CATCH,
+			Actual:   p.peekToken,
+		})
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	level := p.nesting()
 
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
-		block.Statements = append(block.Statements, stmt)
+		if p.recovering {
+			p.synchronize(level)
+			if p.nesting() < level {
+				break
+			}
+		} else if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.EOF) {
+		p.addError(&ParseError{
+			Code:     ErrUnexpectedToken,
+			Span:     p.curToken.Span,
+			Message:  "expected } to close block, got end of input instead",
+			Expected: token.RBRACE,
+			Actual:   p.curToken,
+		})
+	}
+
+	if p.curTokenIs(token.RBRACE) {
+		block.Rbrace = p.curToken
+	}
+
 	return block
 }
 
@@ -358,53 +961,182 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	function.Parameters = p.parseFunctionParameters()
+	function.Parameters, function.Rest = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// A loop around the literal does not extend into its body.
+	loops := p.loops
+	p.loops = 0
 	function.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return function
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameters of a function literal up
+// to the closing parenthesis: names, which may have defaults, patterns and
+// a last ...name collecting the rest of the arguments.
+func (p *Parser) parseFunctionParameters() ([]ast.Pattern, *ast.Identifier) {
+	parameters := []ast.Pattern{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return parameters, nil
 	}
 
-	p.nextToken()
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			rest := p.parseRestParameter()
+			if rest == nil {
+				return nil, nil
+			}
+			p.nextToken()
+			return parameters, rest
+		}
 
-	ident := p.parseIdentifier().(*ast.Identifier)
-	identifiers = append(identifiers, ident)
+		param := p.parseBinding()
+		if param == nil {
+			return nil, nil
+		}
 
-	for p.peekTokenIs(token.COMMA) {
+		if p.peekTokenIs(token.ASSIGN) {
+			if param = p.parseDefault(param); param == nil {
+				return nil, nil
+			}
+		}
+		parameters = append(parameters, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	return parameters, nil
+}
+
+// parseMacroParameters parses the parameters of a macro literal, which
+// are names, possibly with defaults, the last of which may collect the
+// rest of the arguments.
+func (p *Parser) parseMacroParameters() ([]ast.Pattern, *ast.Identifier) {
+	parameters := []ast.Pattern{}
+
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return parameters, nil
+	}
+
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			rest := p.parseRestParameter()
+			if rest == nil {
+				return nil, nil
+			}
+			p.nextToken()
+			return parameters, rest
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil, nil
+		}
+		param := ast.Pattern(p.parseIdentifier().(*ast.Identifier))
+
+		if p.peekTokenIs(token.ASSIGN) {
+			if param = p.parseDefault(param); param == nil {
+				return nil, nil
+			}
+		}
+		parameters = append(parameters, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 		p.nextToken()
-		ident := p.parseIdentifier().(*ast.Identifier)
-		identifiers = append(identifiers, ident)
 	}
 
 	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	return parameters, nil
+}
+
+// parseRestParameter parses the name after the ... at curToken, which
+// must be the last parameter.
+func (p *Parser) parseRestParameter() *ast.Identifier {
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	rest := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.addError(&ParseError{
+			Code:    ErrUnexpectedToken,
+			Span:    p.peekToken.Span,
+			Message: fmt.Sprintf("...%s must be the last parameter", rest.Value),
+			Actual:  p.peekToken,
+		})
 		return nil
 	}
 
-	return identifiers
+	return rest
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	ce := &ast.CallExpression{Token: p.curToken, Function: function}
-	ce.Arguments = p.parseExpressionList(token.RPAREN)
+	ce.Arguments = p.parseCallArguments()
+	ce.Rparen = p.curToken
 	return ce
 }
 
+// parseCallArguments is like parseExpressionList, but an argument may be
+// an array spread with ... into separate arguments.
+func (p *Parser) parseCallArguments() []ast.Expression {
+	args := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return args
+	}
+
+	for {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			args = append(args, spread)
+		} else {
+			args = append(args, p.parseExpression(LOWEST))
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return args
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 
 	return array
 }
@@ -443,6 +1175,8 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 		return nil
 	}
 
+	exp.Rbracket = p.curToken
+
 	return exp
 }
 
@@ -472,6 +1206,8 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
+	hash.Rbrace = p.curToken
+
 	return hash
 }
 
@@ -482,13 +1218,16 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
-	macro.Parameters = p.parseFunctionParameters()
+	macro.Parameters, macro.Rest = p.parseMacroParameters()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	loops := p.loops
+	p.loops = 0
 	macro.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return macro
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +42,52 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestLetPatternStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		names    []string
+	}{
+		{"let [a, b, ...rest] = arr;", "let [a, b, ...rest] = arr;", []string{"a", "b", "rest"}},
+		{`let {"name": n, "age": a} = person;`, "let {name: n, age: a} = person;", []string{"n", "a"}},
+		{"let [x, [y, z = x + 1]] = v;", "let [x, [y, z = (x + 1)]] = v;", []string{"x", "y", "z"}},
+		{`let {name, tags = [], "pos": [_, y]} = h;`, "let {name: name, tags: tags = [], pos: [_, y]} = h;", []string{"name", "tags", "y"}},
+		{"let [] = v;", "let [] = v;", nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.LetStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Name != nil || stmt.Pattern == nil {
+			t.Errorf("stmt binds a name instead of a pattern. got=%v", stmt.Name)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong statement. want=%q, got=%q", tt.expected, program.String())
+		}
+
+		var names []string
+		for _, name := range stmt.Names() {
+			names = append(names, name.Value)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.names, ",") {
+			t.Errorf("stmt binds wrong names. want=%v, got=%v", tt.names, names)
+		}
+	}
+}
+
 func checkParseErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -80,6 +127,36 @@ func testLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	return true
 }
 
+func TestLetStatementDocComments(t *testing.T) {
+	input := `
+/// Doubles x.
+let double = fn(x) { x * 2 };
+
+let plain = 1;
+
+/// Repeats body
+/// n times.
+let repeat = macro(n, body) { body };
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	expected := []string{"Doubles x.", "", "Repeats body\nn times."}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	for i, doc := range expected {
+		stmt := program.Statements[i].(*ast.LetStatement)
+		if stmt.Doc != doc {
+			t.Errorf("statement %d has wrong doc. want=%q, got=%q", i, doc, stmt.Doc)
+		}
+	}
+}
+
 func TestReturnStatment(t *testing.T) {
 	input := `
 		return 5;
@@ -154,6 +231,42 @@ func TestIntegerLiteralExpressions(t *testing.T) {
 	}
 
 	testIntegerLiteral(t, stmt.Expression, 5)
+
+	program = New(lexer.New("18446744073709551616;")).ParseProgram()
+	literal := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IntegerLiteral)
+	if literal.Big == nil || literal.Big.String() != "18446744073709551616" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestFloatLiteralExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5E-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+		if literal.String() != strings.TrimSuffix(tt.input, ";") {
+			t.Errorf("literal.String() wrong. got=%q", literal.String())
+		}
+	}
 }
 
 func testBooleanLiteralExpression(t *testing.T) {
@@ -201,6 +314,35 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"hello ${name}, you have ${len(items) + 1} items"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("wrong number of parts. got=%d", len(str.Parts))
+	}
+	testIdentifier(t, str.Parts[1], "name")
+	if _, ok := str.Parts[3].(*ast.InfixExpression); !ok {
+		t.Errorf("str.Parts[3] not *ast.InfixExpression. got=%T", str.Parts[3])
+	}
+	if str.String() != "hello ${name}, you have ${(len(items) + 1)} items" {
+		t.Errorf("str.String() wrong. got=%q", str.String())
+	}
+	if str.Span().End.Offset != len(input) {
+		t.Errorf("wrong span end. got=%d", str.Span().End.Offset)
+	}
+}
+
 func TestPrefixOperator(t *testing.T) {
 	prefixTests := []struct {
 		input        string
@@ -513,6 +655,417 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"x += y * 2", "x", "+=", "(y * 2)"},
+		{"a[1] -= 1;", "(a[1])", "-=", "1"},
+		{"h[\"k\"][0] *= 3", "((h[k])[0])", "*=", "3"},
+		{"x /= 2", "x", "/=", "2"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.AssignStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if stmt.Target.String() != tt.target {
+			t.Errorf("stmt.Target wrong. expected=%q, got=%q", tt.target, stmt.Target.String())
+		}
+		if stmt.Operator != tt.operator {
+			t.Errorf("stmt.Operator wrong. expected=%q, got=%q", tt.operator, stmt.Operator)
+		}
+		if stmt.Value.String() != tt.value {
+			t.Errorf("stmt.Value wrong. expected=%q, got=%q", tt.value, stmt.Value.String())
+		}
+	}
+}
+
+func TestImportAndExportStatements(t *testing.T) {
+	input := `
+import "lib/strings.mk";
+import s "../shared/strings"
+/// Doubles x.
+export let double = fn(x) { x * 2 };
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			3, len(program.Statements))
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"strings", "lib/strings.mk"},
+		{"s", "../shared/strings"},
+	}
+
+	for i, tt := range tests {
+		stmt, ok := program.Statements[i].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not ast.ImportStatement. got=%T",
+				i, program.Statements[i])
+		}
+		if stmt.Name.Value != tt.name {
+			t.Errorf("stmt.Name wrong. expected=%q, got=%q", tt.name, stmt.Name.Value)
+		}
+		if stmt.Path.Value != tt.path {
+			t.Errorf("stmt.Path wrong. expected=%q, got=%q", tt.path, stmt.Path.Value)
+		}
+	}
+
+	if s := program.Statements[0].String(); s != `import strings "lib/strings.mk";` {
+		t.Errorf("String() wrong. got=%q", s)
+	}
+
+	export, ok := program.Statements[2].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[2] is not ast.ExportStatement. got=%T", program.Statements[2])
+	}
+	if !testLetStatement(t, export.Statement, "double") {
+		return
+	}
+	if export.Statement.Doc != "Doubles x." {
+		t.Errorf("export.Statement.Doc wrong. got=%q", export.Statement.Doc)
+	}
+	if fn, ok := export.Statement.Value.(*ast.FunctionLiteral); !ok || fn.Name != "double" {
+		t.Errorf("exported function not named. got=%T", export.Statement.Value)
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; continue }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body is not 3 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.ContinueStatement); !ok {
+		t.Errorf("Statements[2] is not ast.ContinueStatement. got=%T", stmt.Body.Statements[2])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { item }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T",
+			program.Statements[0])
+	}
+
+	if stmt.Variable.Value != "item" {
+		t.Errorf("stmt.Variable.Value not %q. got=%q", "item", stmt.Variable.Value)
+	}
+
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("stmt.Iterable not %q. got=%q", "[1, 2]", stmt.Iterable.String())
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body is not 1 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+
+	if stmt.String() != "for (item in [1, 2]) item" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestLoopStatementsTakeSemicolons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (i < 3) { i += 1 }; i", "while ((i < 3)) i += 1;i"},
+		{"for (x in a) { x }; a", "for (x in a) xa"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 2 {
+			t.Fatalf("%q: program.Statements does not contain 2 statements. got=%d",
+				tt.input, len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%q: wrong program. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { z }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("alternative is not 1 statements. got=%d\n", len(exp.Alternative.Statements))
+	}
+
+	alternative := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	nested, ok := alternative.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression. got=%T", alternative.Expression)
+	}
+
+	if !testInfixExpression(t, nested.Condition, "x", ">", "y") {
+		return
+	}
+
+	if nested.Alternative == nil || nested.Alternative.String() != "z" {
+		t.Errorf("nested.Alternative wrong. got=%+v", nested.Alternative)
+	}
+
+	if exp.String() != "if(x < y) xelse if(x > y) yelse z" {
+		t.Errorf("exp.String() wrong. got=%q", exp.String())
+	}
+
+	if span := exp.Span(); span.End.Column != len(input)+1 {
+		t.Errorf("exp.Span() does not cover the chain. got=%s", span)
+	}
+}
+
+func TestNullLiteral(t *testing.T) {
+	l := lexer.New("null")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	if _, ok := stmt.Expression.(*ast.NullLiteral); !ok {
+		t.Fatalf("stmt.Expression is not ast.NullLiteral. got=%T", stmt.Expression)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input   string
+		param   string
+		catch   string
+		finally string
+	}{
+		{`try { f(x) } catch (e) { throw e; }`, "e", "throw e;", ""},
+		{`try { f(x) } finally { g() }`, "", "", "g()"},
+		{`try { f(x) } catch (err) { 1 } finally { g() }`, "err", "1", "g()"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+				1, len(program.Statements))
+		}
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T", stmt.Expression)
+		}
+
+		if exp.Block.String() != "f(x)" {
+			t.Errorf("exp.Block wrong. got=%q", exp.Block.String())
+		}
+
+		if tt.catch == "" {
+			if exp.Catch != nil || exp.Param != nil {
+				t.Errorf("exp.Catch is not nil. got=%+v", exp.Catch)
+			}
+		} else {
+			if !testIdentifier(t, exp.Param, tt.param) {
+				return
+			}
+			if exp.Catch == nil || exp.Catch.String() != tt.catch {
+				t.Errorf("exp.Catch wrong. want=%q, got=%+v", tt.catch, exp.Catch)
+			}
+		}
+
+		if tt.finally == "" {
+			if exp.Finally != nil {
+				t.Errorf("exp.Finally is not nil. got=%+v", exp.Finally)
+			}
+		} else if exp.Finally == nil || exp.Finally.String() != tt.finally {
+			t.Errorf("exp.Finally wrong. want=%q, got=%+v", tt.finally, exp.Finally)
+		}
+
+		if span := exp.Span(); span.End.Column != len(tt.input)+1 {
+			t.Errorf("exp.Span() does not cover the expression. got=%s", span)
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.New(`throw "boom" + x;`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.String() != "throw (boom + x);" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (point) {
+		[0, 0] => "origin",
+		[x, -1.5, ...rest] if x > 0 => rest,
+		{"x": x, y, 1: _} => x + y,
+		null => nothing,
+		_ => other
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParseErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Subject, "point") {
+		return
+	}
+
+	tests := []struct {
+		pattern  string
+		guard    string
+		body     string
+		bindings []string
+	}{
+		{"[0, 0]", "", "origin", nil},
+		{"[x, (-1.5), ...rest]", "(x > 0)", "rest", []string{"x", "rest"}},
+		{"{x: x, y: y, 1: _}", "", "(x + y)", []string{"x", "y"}},
+		{"null", "", "nothing", nil},
+		{"_", "", "other", nil},
+	}
+
+	if len(exp.Arms) != len(tests) {
+		t.Fatalf("exp.Arms does not contain %d arms. got=%d", len(tests), len(exp.Arms))
+	}
+
+	for i, tt := range tests {
+		arm := exp.Arms[i]
+
+		if arm.Pattern.String() != tt.pattern {
+			t.Errorf("arms[%d].Pattern wrong. want=%q, got=%q", i, tt.pattern, arm.Pattern.String())
+		}
+
+		guard := ""
+		if arm.Guard != nil {
+			guard = arm.Guard.String()
+		}
+		if guard != tt.guard {
+			t.Errorf("arms[%d].Guard wrong. want=%q, got=%q", i, tt.guard, guard)
+		}
+
+		if arm.Body.String() != tt.body {
+			t.Errorf("arms[%d].Body wrong. want=%q, got=%q", i, tt.body, arm.Body.String())
+		}
+
+		var bindings []string
+		for _, name := range ast.Bindings(arm.Pattern) {
+			bindings = append(bindings, name.Value)
+		}
+		if strings.Join(bindings, ",") != strings.Join(tt.bindings, ",") {
+			t.Errorf("arms[%d] binds wrong names. want=%v, got=%v", i, tt.bindings, bindings)
+		}
+	}
+
+	if span := exp.Span(); span.End.Line != 7 || span.End.Column != 3 {
+		t.Errorf("exp.Span() does not cover the expression. got=%s-%s", span.Start, span.End)
+	}
+}
+
 func TestFunctionLiteral(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := lexer.New(input)
@@ -541,8 +1094,8 @@ func TestFunctionLiteral(t *testing.T) {
 			len(function.Parameters))
 	}
 
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	testLiteralExpression(t, function.Parameters[0].(*ast.Identifier), "x")
+	testLiteralExpression(t, function.Parameters[1].(*ast.Identifier), "y")
 
 	if len(function.Body.Statements) != 1 {
 		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
@@ -562,10 +1115,15 @@ func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
 		expectedParams []string
+		expectedRest   string
 	}{
 		{input: "fn() {};", expectedParams: []string{}},
 		{input: "fn(x) {};", expectedParams: []string{"x"}},
 		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn([a, b = 1], {k}, c) {};", expectedParams: []string{"[a, b = 1]", "{k: k}", "c"}},
+		{input: "fn(x, y = x * 2) {};", expectedParams: []string{"x", "y = (x * 2)"}},
+		{input: "fn(x, ...rest) {};", expectedParams: []string{"x"}, expectedRest: "rest"},
+		{input: "fn(...rest) {};", expectedParams: []string{}, expectedRest: "rest"},
 	}
 
 	for _, tt := range tests {
@@ -582,8 +1140,20 @@ func TestFunctionParameterParsing(t *testing.T) {
 				len(tt.expectedParams), len(function.Parameters))
 		}
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+		for i, param := range tt.expectedParams {
+			if ident, ok := function.Parameters[i].(*ast.Identifier); ok {
+				testLiteralExpression(t, ident, param)
+			} else if function.Parameters[i].String() != param {
+				t.Errorf("parameter %d wrong. want=%q, got=%q", i, param, function.Parameters[i].String())
+			}
+		}
+
+		rest := ""
+		if function.Rest != nil {
+			rest = function.Rest.Value
+		}
+		if rest != tt.expectedRest {
+			t.Errorf("rest parameter wrong. want=%q, got=%q", tt.expectedRest, rest)
 		}
 	}
 }
@@ -828,6 +1398,46 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 	}
 }
 
+func TestMacroParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+		expectedRest   string
+	}{
+		{input: "macro() {};", expectedParams: []string{}},
+		{input: "macro(x, y = quote(x + 1)) {};", expectedParams: []string{"x", "y = quote((x + 1))"}},
+		{input: "macro(x = 1, ...rest) {};", expectedParams: []string{"x = 1"}, expectedRest: "rest"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		macro := stmt.Expression.(*ast.MacroLiteral)
+
+		if len(macro.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(macro.Parameters))
+		}
+
+		for i, param := range tt.expectedParams {
+			if macro.Parameters[i].String() != param {
+				t.Errorf("parameter %d wrong. want=%q, got=%q", i, param, macro.Parameters[i].String())
+			}
+		}
+
+		rest := ""
+		if macro.Rest != nil {
+			rest = macro.Rest.Value
+		}
+		if rest != tt.expectedRest {
+			t.Errorf("rest parameter wrong. want=%q, got=%q", tt.expectedRest, rest)
+		}
+	}
+}
+
 func TestMacroLiteralParsing(t *testing.T) {
 	input := `macro(x, y) { x + y; }`
 
@@ -858,8 +1468,8 @@ func TestMacroLiteralParsing(t *testing.T) {
 			len(macro.Parameters))
 	}
 
-	testLiteralExpression(t, macro.Parameters[0], "x")
-	testLiteralExpression(t, macro.Parameters[1], "y")
+	testLiteralExpression(t, macro.Parameters[0].(*ast.Identifier), "x")
+	testLiteralExpression(t, macro.Parameters[1].(*ast.Identifier), "y")
 
 	if len(macro.Body.Statements) != 1 {
 		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
@@ -972,6 +1582,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
 			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
 		},
+		{
+			"add(a, ...b, ...[1, 2 * 3])",
+			"add(a, ...b, ...[1, (2 * 3)])",
+		},
 		{
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
@@ -984,6 +1598,46 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a <= b == c >= d",
+			"((a <= b) == (c >= d))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"a | b ^ c & d",
+			"((a | b) ^ (c & d))",
+		},
+		{
+			"1 << 2 + 3 >> 1",
+			"((1 << 2) + (3 >> 1))",
+		},
+		{
+			"a < b << 1",
+			"(a < (b << 1))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"-2 ** 2 * 3",
+			"((-(2 ** 2)) * 3)",
+		},
+		{
+			"2 ** -x",
+			"(2 ** (-x))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -998,3 +1652,238 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestNodeSpans(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + b * c", "1:1-1:10"},
+		{"let x = add(1, 2);", "1:1-1:18"},
+		{"foo[1]", "1:1-1:7"},
+		{"[1,\n 2]", "1:1-2:4"},
+		{"{\"a\": 1}", "1:1-1:9"},
+		{"if (x) { 1 } else { 2 }", "1:1-1:24"},
+		{"fn(x) {\n  x\n}", "1:1-3:2"},
+		{"return -x;", "1:1-1:10"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParseErrors(t, p)
+
+		span := program.Statements[0].Span()
+		actual := fmt.Sprintf("%d:%d-%d:%d", span.Start.Line, span.Start.Column,
+			span.End.Line, span.End.Column)
+		if actual != tt.expected {
+			t.Errorf("wrong span for %q. expected=%s, got=%s",
+				tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+		codes    []ErrorCode
+	}{
+		{
+			"let = 5;",
+			[]string{"1:5: expected identifier, got = instead"},
+			[]ErrorCode{ErrUnexpectedToken},
+		},
+		{
+			"let x = (1 + ;",
+			[]string{"1:14: expected expression, got ; instead"},
+			[]ErrorCode{ErrNoPrefixParseFn},
+		},
+		{
+			"let x 5; let y = 10; y + ;",
+			[]string{
+				"1:7: expected =, got integer 5 instead",
+				"1:26: expected expression, got ; instead",
+			},
+			[]ErrorCode{ErrUnexpectedToken, ErrNoPrefixParseFn},
+		},
+		{
+			"let f = fn(x) {\n let = 1;\n x +\n};\nlet g = fn(1) { 1 };",
+			[]string{
+				"2:6: expected identifier, got = instead",
+				"4:1: expected expression, got } instead",
+				"5:12: expected identifier, got integer 1 instead",
+			},
+			[]ErrorCode{ErrUnexpectedToken, ErrNoPrefixParseFn, ErrUnexpectedToken},
+		},
+		{
+			"let g = add(1, ;\nlet h = 3 *;\nlet q = [1, 2;\nlet f = fn() { g(x, ;\n return 1 };\nlet 2.5 = r;",
+			[]string{
+				"1:16: expected expression, got ; instead",
+				"2:12: expected expression, got ; instead",
+				"3:14: expected ], got ; instead",
+				"4:21: expected expression, got ; instead",
+				"6:5: expected identifier, got float 2.5 instead",
+			},
+			[]ErrorCode{ErrNoPrefixParseFn, ErrNoPrefixParseFn, ErrUnexpectedToken, ErrNoPrefixParseFn, ErrUnexpectedToken},
+		},
+		{
+			`let h = {"a" 1, "b": 2};`,
+			[]string{"1:14: expected :, got integer 1 instead"},
+			[]ErrorCode{ErrUnexpectedToken},
+		},
+		{
+			"if (x) { 1",
+			[]string{"1:11: expected } to close block, got end of input instead"},
+			[]ErrorCode{ErrUnexpectedToken},
+		},
+		{
+			"5 + @; }; 1 +",
+			[]string{
+				"1:5: illegal character \"@\"",
+				"1:8: expected expression, got } instead",
+				"1:14: expected expression, got end of input instead",
+			},
+			[]ErrorCode{ErrIllegalCharacter, ErrNoPrefixParseFn, ErrNoPrefixParseFn},
+		},
+		{
+			`puts("hi\q"); let s = "open`,
+			[]string{
+				"1:9: unknown escape sequence \\q",
+				"1:23: unterminated string literal",
+			},
+			[]ErrorCode{ErrInvalidToken, ErrInvalidToken},
+		},
+		{
+			`let s = "a ${x y} b";`,
+			[]string{"1:16: expected } to close interpolation, got identifier \"y\" instead"},
+			[]ErrorCode{ErrUnexpectedToken},
+		},
+		{
+			`let s = "${}"; let t = "a ${} b ${x}";`,
+			[]string{
+				"1:12: empty interpolation, expected expression in ${...}",
+				"1:29: empty interpolation, expected expression in ${...}",
+			},
+			[]ErrorCode{ErrNoPrefixParseFn, ErrNoPrefixParseFn},
+		},
+		{
+			`let s = "a ${1 + } b";`,
+			[]string{"1:18: expected expression in ${...}, got }"},
+			[]ErrorCode{ErrNoPrefixParseFn},
+		},
+		{
+			"let x = 1; /* unfinished",
+			[]string{"1:12: unterminated block comment"},
+			[]ErrorCode{ErrInvalidToken},
+		},
+		{
+			"break; while (true) { let f = fn() { continue; }; }",
+			[]string{
+				"1:1: break outside of a loop",
+				"1:38: continue outside of a loop",
+			},
+			[]ErrorCode{ErrOutsideLoop, ErrOutsideLoop},
+		},
+		{
+			"for (1 in xs) { x } let y = 2;",
+			[]string{"1:6: expected identifier, got integer 1 instead"},
+			[]ErrorCode{ErrUnexpectedToken},
+		},
+		{
+			"1 = 2; f() += 1;",
+			[]string{
+				"1:1: cannot assign to 1",
+				"1:8: cannot assign to f()",
+			},
+			[]ErrorCode{ErrInvalidAssignment, ErrInvalidAssignment},
+		},
+		{
+			`fn() { import "a" }; while (true) { export let x = 1; } import "b"`,
+			[]string{
+				"1:8: import is only allowed at the top level",
+				"1:37: export is only allowed at the top level",
+			},
+			[]ErrorCode{ErrNotTopLevel, ErrNotTopLevel},
+		},
+		{
+			`import "lib/my-strings"; import "lib/"; import 5; export fn() {};`,
+			[]string{
+				"1:8: cannot derive a name from import path \"lib/my-strings\", use import name \"lib/my-strings\"",
+				"1:33: cannot derive a name from import path \"lib/\", use import name \"lib/\"",
+				"1:48: expected string, got integer 5 instead",
+				"1:58: expected let, got fn instead",
+			},
+			[]ErrorCode{ErrInvalidImport, ErrInvalidImport, ErrUnexpectedToken, ErrUnexpectedToken},
+		},
+		{
+			"try { 1 }; try { } catch e { }; throw;",
+			[]string{
+				"1:10: expected catch or finally after try block, got ; instead",
+				"1:26: expected (, got identifier \"e\" instead",
+				"1:38: expected expression, got ; instead",
+			},
+			[]ErrorCode{ErrUnexpectedToken, ErrUnexpectedToken, ErrNoPrefixParseFn},
+		},
+		{
+			`match (x) { [a, {"k": a}] => 1 }; match (x) { [...r, b] => 1 }; match (x) { f(1) => 2 }; match (x) { -a => 1 }`,
+			[]string{
+				"1:23: a is bound more than once in the pattern",
+				"1:52: ...r must be the last element of the pattern",
+				"1:78: expected =>, got ( instead",
+				"1:103: expected a pattern, got identifier \"a\" instead",
+			},
+			[]ErrorCode{ErrInvalidPattern, ErrInvalidPattern, ErrUnexpectedToken, ErrInvalidPattern},
+		},
+		{
+			`let [a, a] = x; let [1 = 2] = x; fn([b, {"k": b}]) { b }; let 5 = x`,
+			[]string{
+				"1:9: a is bound more than once in the pattern",
+				"1:24: only a name can have a default, not 1",
+				"1:47: b is bound more than once in the pattern",
+				"1:63: expected identifier, got integer 5 instead",
+			},
+			[]ErrorCode{ErrInvalidPattern, ErrInvalidPattern, ErrInvalidPattern, ErrUnexpectedToken},
+		},
+		{
+			`fn(...a, b) { a }; fn([a] = 1) { a }; macro(...m, n) { m }; [...a]`,
+			[]string{
+				"1:8: ...a must be the last parameter",
+				"1:27: only a name can have a default, not [a]",
+				"1:49: ...m must be the last parameter",
+				"1:62: expected expression, got ... instead",
+			},
+			[]ErrorCode{ErrUnexpectedToken, ErrInvalidPattern, ErrUnexpectedToken, ErrNoPrefixParseFn},
+		},
+		{
+			"let big = 1e999;",
+			[]string{"1:11: could not parse \"1e999\" as float"},
+			[]ErrorCode{ErrInvalidFloat},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%d, got=%d (%v)",
+				tt.input, len(tt.expected), len(errors), errors)
+			continue
+		}
+
+		for i, err := range errors {
+			if err.Error() != tt.expected[i] {
+				t.Errorf("wrong error for %q. expected=%q, got=%q",
+					tt.input, tt.expected[i], err.Error())
+			}
+			if err.Code != tt.codes[i] {
+				t.Errorf("wrong code for %q. expected=%s, got=%s",
+					tt.input, tt.codes[i], err.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package parser
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/token"
+)
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	exp.Rbrace = p.curToken
+
+	return exp
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Pattern: p.parsePattern()}
+	if arm.Pattern == nil || !p.checkBindings(arm.Pattern) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	if arm.Body == nil {
+		return nil
+	}
+
+	return arm
+}
+
+// parseBinding parses what follows let, or a function parameter: the
+// name after curToken, or an array or hash pattern.
+func (p *Parser) parseBinding() ast.Pattern {
+	if !p.peekTokenIs(token.LBRACKET) && !p.peekTokenIs(token.LBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	p.nextToken()
+	pattern := p.parsePattern()
+	if pattern == nil || !p.checkBindings(pattern) {
+		return nil
+	}
+
+	return pattern
+}
+
+// parsePattern parses the pattern starting at curToken.
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		return p.parseLiteralPattern()
+	case token.MINUS:
+		if !p.peekTokenIs(token.INT) && !p.peekTokenIs(token.FLOAT) {
+			p.patternError(p.peekToken)
+			return nil
+		}
+		return p.parseLiteralPattern()
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		p.patternError(p.curToken)
+		return nil
+	}
+}
+
+// parseLiteralPattern parses a literal, or a negated number, without the
+// operators that may follow it in an expression.
+func (p *Parser) parseLiteralPattern() ast.Pattern {
+	if p.curTokenIs(token.MINUS) {
+		exp := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+		p.nextToken()
+		exp.Right = p.prefixParseFns[p.curToken.Type]()
+		if exp.Right == nil {
+			return nil
+		}
+		return &ast.LiteralPattern{Value: exp}
+	}
+
+	value := p.prefixParseFns[p.curToken.Type]()
+	if value == nil {
+		return nil
+	}
+	return &ast.LiteralPattern{Value: value}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+			if !p.peekTokenIs(token.RBRACKET) {
+				p.addError(&ParseError{
+					Code:    ErrInvalidPattern,
+					Span:    p.peekToken.Span,
+					Message: fmt.Sprintf("...%s must be the last element of the pattern", pattern.Rest.Value),
+					Actual:  p.peekToken,
+				})
+				return nil
+			}
+			break
+		}
+
+		element := p.parsePatternElement()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	pattern.Rbracket = p.curToken
+
+	return pattern
+}
+
+// parseHashPattern parses a hash pattern. An identifier on its own, as in
+// {name}, stands for the key of that name bound to the same name.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		var key ast.Expression
+		var value ast.Pattern
+
+		switch p.curToken.Type {
+		case token.IDENT:
+			keyToken := p.curToken
+			keyToken.Type = token.STRING
+			key = &ast.StringLiteral{Token: keyToken, Value: keyToken.Literal}
+
+			value = p.parsePatternElement()
+			if value == nil {
+				return nil
+			}
+		case token.STRING, token.INT, token.TRUE, token.FALSE:
+			key = p.prefixParseFns[p.curToken.Type]()
+			if key == nil {
+				return nil
+			}
+
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			p.nextToken()
+
+			value = p.parsePatternElement()
+			if value == nil {
+				return nil
+			}
+		default:
+			p.addError(&ParseError{
+				Code:    ErrInvalidPattern,
+				Span:    p.curToken.Span,
+				Message: fmt.Sprintf("expected a hash key in pattern, got %s instead", describe(p.curToken)),
+				Actual:  p.curToken,
+			})
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	pattern.Rbrace = p.curToken
+
+	return pattern
+}
+
+// parsePatternElement parses an element of an array or hash pattern,
+// which is a pattern or a name followed by = and its default.
+func (p *Parser) parsePatternElement() ast.Pattern {
+	pattern := p.parsePattern()
+	if pattern == nil || !p.peekTokenIs(token.ASSIGN) {
+		return pattern
+	}
+
+	return p.parseDefault(pattern)
+}
+
+// parseDefault parses the = and default that follow pattern, which must
+// be a name.
+func (p *Parser) parseDefault(pattern ast.Pattern) ast.Pattern {
+	name, ok := pattern.(*ast.Identifier)
+	if !ok || name.Value == "_" {
+		p.addError(&ParseError{
+			Code:    ErrInvalidPattern,
+			Span:    p.peekToken.Span,
+			Message: fmt.Sprintf("only a name can have a default, not %s", pattern.String()),
+			Actual:  p.peekToken,
+		})
+		return nil
+	}
+
+	p.nextToken()
+	element := &ast.DefaultPattern{Token: p.curToken, Name: name}
+
+	p.nextToken()
+	element.Default = p.parseExpression(LOWEST)
+	if element.Default == nil {
+		return nil
+	}
+
+	return element
+}
+
+// checkBindings reports whether pattern binds each name at most once, and
+// adds an error if it does not.
+func (p *Parser) checkBindings(pattern ast.Pattern) bool {
+	seen := map[string]bool{}
+
+	for _, name := range ast.Bindings(pattern) {
+		if seen[name.Value] {
+			p.addError(&ParseError{
+				Code:    ErrInvalidPattern,
+				Span:    name.Span(),
+				Message: fmt.Sprintf("%s is bound more than once in the pattern", name.Value),
+				Actual:  name.Token,
+			})
+			return false
+		}
+		seen[name.Value] = true
+	}
+
+	return true
+}
+
+func (p *Parser) patternError(t token.Token) {
+	p.addError(&ParseError{
+		Code:    ErrInvalidPattern,
+		Span:    t.Span,
+		Message: fmt.Sprintf("expected a pattern, got %s instead", describe(t)),
+		Actual:  t,
+	})
+}
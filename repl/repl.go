@@ -4,53 +4,101 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/compiler"
 	"monkey/eval"
 	"monkey/lexer"
+	"monkey/module"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/token"
+	"monkey/vm"
+	"strconv"
+	"strings"
 )
 
 const PROMPT = ">>"
 
-const MONKEY_FACE = `            __,__
-   .--.  .-"     "-.  .--.
-  / .. \/  .-. .-.  \/ .. \
- | |  '|  /   Y   \  |'  | |
- | \   \  \ 0 | 0 /  /   / |
-  \ '- ,\.-"""""""-./, -' /
-   ''-' /_   ^ ^   _\ '-''
-       |  \._   _./  |
-       \   \ '~' /   /
-        '._ '-=-' _.'
-           '-----'
-`
+// CONTINUATION_PROMPT is shown while a statement spans several lines.
+const CONTINUATION_PROMPT = ".."
+
+// ABORT_COMMAND discards the pending lines of an incomplete statement.
+const ABORT_COMMAND = ".break"
+
+// The engines a REPL can run input with.
+const (
+	EngineEval = "eval"
+	EngineVM   = "vm"
+)
 
 func Start(in io.Reader, out io.Writer) {
+	StartEngine(in, out, EngineEval)
+}
+
+// StartEngine is like Start but runs each line with the given engine.
+func StartEngine(in io.Reader, out io.Writer, engine string) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+
+	// Relative imports are resolved against the working directory.
+	loader := &module.Loader{Path: module.PathFromEnv(), VM: engine == EngineVM}
 	macroEnv := object.NewEnvironment()
+	macroEnv.SetImporter(loader)
+
+	run := evalRunner(loader)
+	if engine == EngineVM {
+		run = vmRunner(loader)
+	}
+
+	var pending []string
 
 	for {
-		fmt.Fprint(out, PROMPT)
+		if len(pending) == 0 {
+			fmt.Fprint(out, PROMPT)
+		} else {
+			fmt.Fprint(out, CONTINUATION_PROMPT)
+		}
+
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+		if len(pending) > 0 && strings.TrimSpace(line) == ABORT_COMMAND {
+			pending = nil
+			continue
+		}
+
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
+		if isIncomplete(input) {
+			continue
+		}
+		pending = nil
+
+		l := lexer.New(input)
 		p := parser.New(l)
 		program := p.ParseProgram()
 
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			PrintParserErrors(out, input, p.Errors())
 			continue
 		}
 
 		eval.DefineMacros(program, macroEnv)
 		expanded := eval.ExpandMacros(program, macroEnv)
 
-		evaluated := eval.Eval(expanded, env)
+		evaluated, err := run(expanded)
+		if err != nil {
+			fmt.Fprintf(out, "compile error: %s\n", err)
+			continue
+		}
+
+		if errObj, ok := evaluated.(*object.Error); ok {
+			PrintRuntimeError(out, input, errObj)
+			continue
+		}
+
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -59,11 +107,131 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, MONKEY_FACE)
-	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// runner executes one line of input and returns its value. State such as
+// bindings carries over from one call to the next.
+type runner func(program ast.Node) (object.Object, error)
+
+func evalRunner(importer object.Importer) runner {
+	env := object.NewEnvironment()
+	env.SetImporter(importer)
+
+	return func(program ast.Node) (object.Object, error) {
+		return eval.Eval(program, env), nil
 	}
 }
+
+func vmRunner(importer object.Importer) runner {
+	symbolTable := compiler.New().SymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+
+	return func(program ast.Node) (object.Object, error) {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			return nil, err
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalsStore(bytecode, globals)
+		machine.SetImporter(importer)
+		return machine.Run(), nil
+	}
+}
+
+// isIncomplete reports whether input ends inside an unclosed bracket,
+// string literal or block comment, meaning more lines are needed before it
+// can be parsed.
+func isIncomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+	}
+
+	for _, err := range l.Errors() {
+		if err.Incomplete {
+			return true
+		}
+	}
+
+	return depth > 0
+}
+
+// PrintParserErrors writes each error followed by the offending line of
+// src with a caret under the error position.
+func PrintParserErrors(out io.Writer, src string, errors []*parser.ParseError) {
+	for _, err := range errors {
+		fmt.Fprintf(out, "error[%s]: %s\n", err.Code, err.Message)
+		writeSnippet(out, src, err.Span)
+	}
+}
+
+// PrintRuntimeError writes the traceback of err followed by the line of
+// src where it was raised. If it was raised in another file, such as an
+// imported module, the line of src that led there is shown instead.
+func PrintRuntimeError(out io.Writer, src string, err *object.Error) {
+	io.WriteString(out, err.Traceback())
+	io.WriteString(out, "\n")
+
+	span := err.Span
+	if n := len(err.Stack); n > 0 && err.Stack[n-1].Call.Start.Filename != span.Start.Filename {
+		span = err.Stack[n-1].Call
+	}
+	writeSnippet(out, src, span)
+}
+
+// writeSnippet prints the source line containing span with carets
+// underlining it. Nothing is printed if span has no position or src has no
+// such line, as for errors of functions called from Go.
+func writeSnippet(out io.Writer, src string, span token.Span) {
+	if !span.IsValid() {
+		return
+	}
+
+	lines := strings.Split(src, "\n")
+	start := span.Start
+	if start.Line > len(lines) {
+		return
+	}
+
+	line := strings.TrimRight(lines[start.Line-1], "\r")
+	if start.Line == 1 {
+		line = strings.TrimPrefix(line, "\uFEFF")
+	}
+	if line == "" {
+		return
+	}
+	number := strconv.Itoa(start.Line)
+	gutter := strings.Repeat(" ", len(number))
+
+	fmt.Fprintf(out, "%s--> %s\n", gutter, start)
+	fmt.Fprintf(out, "%s |\n", gutter)
+	fmt.Fprintf(out, "%s | %s\n", number, line)
+
+	// Columns count runes, so index the line by rune to align the marker.
+	runes := []rune(line)
+	var marker strings.Builder
+	for i := 0; i < start.Column-1; i++ {
+		if i < len(runes) && runes[i] == '\t' {
+			marker.WriteByte('\t')
+		} else {
+			marker.WriteByte(' ')
+		}
+	}
+
+	width := 1
+	if span.End.Line == start.Line && span.End.Column > start.Column {
+		width = span.End.Column - start.Column
+	}
+	marker.WriteString(strings.Repeat("^", width))
+
+	fmt.Fprintf(out, "%s | %s\n", gutter, marker.String())
+}
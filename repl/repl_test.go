@@ -0,0 +1,118 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/lexer"
+	"monkey/parser"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n a + b\n};", false},
+		{"let h = {\"a\": [1,", true},
+		{"puts(\"hello", true},
+		{"puts(\"hello\")", false},
+		{"\"", true},
+		{"\"{\"", false},
+		{"}", false},
+		{"let s = \"a\\\"", true},
+		{"let s = `first line", true},
+		{"let s = `first\nsecond`;", false},
+		{"puts(\"total: ${", true},
+		{"puts(\"total: ${1 + 2}\")", false},
+		{"let x = 5; /* note", true},
+		{"let x = 5; /* note\n */", false},
+		{"let f = fn() { // {", true},
+		{"let f = fn() { 1 } // {", false},
+	}
+
+	for _, tt := range tests {
+		if actual := isIncomplete(tt.input); actual != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. expected=%t, got=%t",
+				tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestStartMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1,
+2)
+let broken = fn(x) {
+.break
+add(2, 3)
+`
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+
+	expected := ">>....>>..3\n>>..>>5\n>>"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestStartEngineVM(t *testing.T) {
+	input := `let x = 2;
+let double = fn(y) { y * x };
+double(21)
+double(z)
+`
+
+	var out bytes.Buffer
+	StartEngine(strings.NewReader(input), &out, EngineVM)
+
+	expected := ">>>>>>42\n>>Traceback (most recent call last):\n" +
+		"  at 1:8 in <main>\n" +
+		"Error: identifier not found: z\n" +
+		" --> 1:8\n  |\n1 | double(z)\n  |        ^\n>>"
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestPrintParserErrors(t *testing.T) {
+	input := "let x = 1;\n\tlet = 5;"
+
+	var out bytes.Buffer
+	Start(strings.NewReader(input+"\n"), &out)
+
+	expected := ">>>>" + `error[E001]: expected identifier, got = instead
+ --> 1:6
+  |
+1 | 	let = 5;
+  | 	    ^
+>>`
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestPrintParserErrorsUnicode(t *testing.T) {
+	src := "\uFEFFlet größe = \t§;"
+	l := lexer.New(src)
+	p := parser.New(l)
+	p.ParseProgram()
+
+	var out bytes.Buffer
+	PrintParserErrors(&out, src, p.Errors())
+
+	expected := `error[E004]: illegal character "§"
+ --> 1:14
+  |
+1 | let größe = 	§;
+  |             	^
+`
+	if out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/module"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/vm"
+	"os"
+)
+
+// execute runs src through the same pipeline as the REPL, using the given
+// engine, and returns the process exit code. When printResult is set the
+// value of the program is written to stdout.
+func execute(filename, src string, args []string, engine string, printResult bool) int {
+	l := lexer.NewFile(filename, src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		repl.PrintParserErrors(os.Stderr, src, p.Errors())
+		return exitParseError
+	}
+
+	loader := &module.Loader{Path: module.PathFromEnv(), VM: engine == repl.EngineVM}
+	defer loader.EnterMain(filename)()
+
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetImporter(loader)
+	eval.DefineMacros(program, macroEnv)
+	expanded := eval.ExpandMacros(program, macroEnv)
+
+	var evaluated object.Object
+	if engine == repl.EngineVM {
+		var err error
+		evaluated, err = runCompiled(expanded, argsArray(args), loader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "compile error: %s\n", err)
+			return exitRuntimeError
+		}
+	} else {
+		env := object.NewEnvironment()
+		env.SetImporter(loader)
+		env.Set("args", argsArray(args))
+		evaluated = eval.Eval(expanded, env)
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		repl.PrintRuntimeError(os.Stderr, src, errObj)
+		return exitRuntimeError
+	}
+
+	if printResult && evaluated != nil {
+		fmt.Fprintln(os.Stdout, evaluated.Inspect())
+	}
+
+	return exitOK
+}
+
+// runCompiled compiles program and runs it on the vm with args bound as a
+// global, importing modules with importer.
+func runCompiled(program ast.Node, args *object.Array, importer object.Importer) (object.Object, error) {
+	comp := compiler.New()
+	symbol := comp.SymbolTable().Define("args")
+
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	globals := make([]object.Object, vm.GlobalsSize)
+	globals[symbol.Index] = args
+
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	machine.SetImporter(importer)
+	return machine.Run(), nil
+}
+
+func argsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+
+	return &object.Array{Elements: elements}
+}
@@ -1,12 +1,55 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
+	// Doc is the text of the /// comment lines directly above the token,
+	// without the slashes and joined by newlines.
+	Doc string
+}
+
+// Position is a location in a source file. Offset is a byte offset
+// starting at 0, Line and Column start at 1.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
 }
 
+// IsValid reports whether the position was set by the lexer.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Span is the source range covered by a token or node. End points just
+// past the last character.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) IsValid() bool { return s.Start.IsValid() }
+
+func (s Span) String() string { return s.Start.String() }
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
@@ -14,8 +57,15 @@ const (
 	// Identifiers and literals
 	IDENT  = "IDENT" // add, foobar, x, y ...
 	INT    = "INT"   // 1234...
+	FLOAT  = "FLOAT" // 3.14, 1e9 ...
 	STRING = "STRING"
 
+	// Parts of an interpolated string such as "a ${x} b ${y} c", which
+	// lexes as STRING_HEAD "a ", x, STRING_MIDDLE " b ", y, STRING_TAIL " c".
+	STRING_HEAD   = "STRING_HEAD"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_TAIL   = "STRING_TAIL"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -23,11 +73,32 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
+
+	EQ    = "=="
+	NEQ   = "!="
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+
+	ARROW    = "=>"
+	ELLIPSIS = "..."
 
-	EQ  = "=="
-	NEQ = "!="
-	LT  = "<"
-	GT  = ">"
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
 	// Delimiters
 	COMMA     = ","
@@ -50,17 +121,43 @@ const (
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	MACRO    = "MACRO"
+	NULL     = "NULL"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	MATCH    = "MATCH"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"return": RETURN,
-	"if":     IF,
-	"else":   ELSE,
-	"true":   TRUE,
-	"false":  FALSE,
-	"macro":  MACRO,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"return":   RETURN,
+	"if":       IF,
+	"else":     ELSE,
+	"true":     TRUE,
+	"false":    FALSE,
+	"macro":    MACRO,
+	"null":     NULL,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"match":    MATCH,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,30 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+	"monkey/token"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+
+	// callSite is the offset of the OpCall instruction in the calling
+	// frame, used to build tracebacks.
+	callSite int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// position returns the source span of the instruction at offset ip.
+func (f *Frame) position(ip int) token.Span {
+	return f.cl.Fn.Positions[ip]
+}
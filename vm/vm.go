@@ -0,0 +1,869 @@
+package vm
+
+import (
+	"monkey/ast"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/object"
+)
+
+// StackSize is the number of stack slots a vm starts with. The stack grows
+// as calls nest, up to MaxStackSize slots.
+const StackSize = 2048
+const MaxStackSize = 1 << 20
+const GlobalsSize = 65536
+
+// MaxFrames bounds the frames of a vm. Like the evaluator, it allows
+// eval.DefaultMaxCallDepth nested calls on top of the program.
+const MaxFrames = eval.DefaultMaxCallDepth + 1
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus: "-",
+	code.OpBang:  "!",
+}
+
+type VM struct {
+	// unit holds the constants and globals of the program. Closures carry
+	// their own, so the instructions of a frame use those of its closure.
+	unit *object.Unit
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	builtins []*object.Builtin
+	importer object.Importer
+
+	// handlers holds the handlers of the try expressions being run, the
+	// innermost last.
+	handlers []handler
+}
+
+// handler is where execution resumes when an error is raised inside a try
+// expression.
+type handler struct {
+	frames int // the number of frames when the try expression started
+	sp     int
+	ip     int // the offset of the handler in the instructions of its frame
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	unit := &object.Unit{
+		Constants:   bytecode.Constants,
+		Globals:     make([]object.Object, GlobalsSize),
+		GlobalNames: bytecode.GlobalNames,
+	}
+
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn, Unit: unit}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := []*Frame{mainFrame}
+
+	builtins := []*object.Builtin{}
+	for _, name := range eval.BuiltinNames() {
+		builtin, _ := eval.LookupBuiltin(name)
+		builtins = append(builtins, builtin)
+	}
+
+	return &VM{
+		unit: unit,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+
+		builtins: builtins,
+	}
+}
+
+// NewWithGlobalsStore returns a vm that shares its globals with an earlier
+// one, as the REPL does between lines.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.unit.Globals = s
+	return vm
+}
+
+// Globals returns the global slots, to be handed to NewWithGlobalsStore.
+func (vm *VM) Globals() []object.Object {
+	return vm.unit.Globals
+}
+
+// SetImporter makes the vm load the modules the program imports with
+// importer. Without one, import statements fail.
+func (vm *VM) SetImporter(importer object.Importer) {
+	vm.importer = importer
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) *object.Error {
+	if vm.framesIndex >= MaxFrames {
+		return callDepthExceeded()
+	}
+
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+	return nil
+}
+
+// stackOverflow returns the error for a program that recursed too deeply.
+// Like the call depth limit of the evaluator, it cannot be caught.
+func stackOverflow() *object.Error {
+	err := eval.NewError(object.LimitError, "stack overflow")
+	err.Limit = object.CallDepthLimit
+	return err
+}
+
+// callDepthExceeded returns the error for a program that nested more calls
+// than MaxFrames allows, which is the one the evaluator reports.
+func callDepthExceeded() *object.Error {
+	err := eval.NewError(object.LimitError, "call depth limit of %d exceeded", eval.DefaultMaxCallDepth)
+	err.Limit = object.CallDepthLimit
+	return err
+}
+
+// growStack makes room for at least n stack slots. It reports false if
+// that would exceed MaxStackSize.
+func (vm *VM) growStack(n int) bool {
+	if n <= len(vm.stack) {
+		return true
+	}
+	if n > MaxStackSize {
+		return false
+	}
+
+	size := 2 * len(vm.stack)
+	for size < n {
+		size *= 2
+	}
+	if size > MaxStackSize {
+		size = MaxStackSize
+	}
+
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack[:vm.sp])
+	vm.stack = stack
+	return true
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run executes the program and returns its value: the value of the last
+// expression statement or top-level return, nil if there is none, or an
+// *object.Error if execution failed.
+func (vm *VM) Run() object.Object {
+	for {
+		result := vm.run()
+
+		// Errors that stopped a program exceeding a limit cannot be caught.
+		err, ok := result.(*object.Error)
+		if !ok || err.Limit != "" || len(vm.handlers) == 0 {
+			return result
+		}
+
+		vm.catch(err)
+	}
+}
+
+// catch unwinds the frames and the stack to the innermost handler and
+// resumes execution there with err as the caught exception.
+func (vm *VM) catch(err *object.Error) {
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	// Only the calls that were unwound stay on the stack of err, as in the
+	// evaluator; they are the innermost ones.
+	err.Stack = err.Stack[:len(err.Stack)-(h.frames-1)]
+
+	vm.framesIndex = h.frames
+	vm.sp = h.sp
+	vm.push(&object.Exception{Err: err})
+	vm.currentFrame().ip = h.ip - 1
+}
+
+// run executes instructions until the program ends or an error is raised.
+func (vm *VM) run() object.Object {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for {
+		frame := vm.currentFrame()
+		unit := frame.cl.Unit
+		frame.ip++
+
+		ip = frame.ip
+		ins = frame.Instructions()
+		if ip >= len(ins) {
+			return nil
+		}
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			if err := vm.push(unit.Constants[constIndex]); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpMod, code.OpPow, code.OpGreaterEqual, code.OpLessEqual,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			right := vm.pop()
+			left := vm.pop()
+
+			result := eval.InfixOperator(infixOperators[op], left, right)
+			if err, ok := result.(*object.Error); ok {
+				return vm.fail(ip, err)
+			}
+			vm.push(result)
+
+		case code.OpMinus, code.OpBang:
+			right := vm.pop()
+
+			result := eval.PrefixOperator(prefixOperators[op], right)
+			if err, ok := result.(*object.Error); ok {
+				return vm.fail(ip, err)
+			}
+			vm.push(result)
+
+		case code.OpTrue:
+			if err := vm.push(eval.TRUE); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpFalse:
+			if err := vm.push(eval.FALSE); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpNull:
+			if err := vm.push(eval.NULL); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			condition := vm.pop()
+			if !eval.IsTruthy(condition) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			unit.Globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			value := unit.Globals[globalIndex]
+			if value == nil {
+				return vm.fail(ip, identifierNotFound(unit.GlobalNames, int(globalIndex)))
+			}
+
+			if err := vm.push(value); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			if c, ok := (*slot).(*cell); ok {
+				c.value = vm.pop()
+			} else {
+				*slot = vm.pop()
+			}
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			value := deref(vm.stack[frame.basePointer+int(localIndex)])
+			if value == nil {
+				return vm.fail(ip, identifierNotFound(frame.cl.Fn.LocalNames, int(localIndex)))
+			}
+
+			if err := vm.push(value); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.push(vm.builtins[builtinIndex]); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			value := deref(frame.cl.Free[freeIndex])
+			if value == nil {
+				return vm.fail(ip, identifierNotFound(frame.cl.Fn.FreeNames, int(freeIndex)))
+			}
+
+			if err := vm.push(value); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			c, ok := frame.cl.Free[freeIndex].(*cell)
+			if !ok {
+				return vm.fail(ip, eval.NewError(object.NameError, "cannot assign to the name of a function inside it"))
+			}
+			c.value = vm.pop()
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			slot := &vm.stack[frame.basePointer+int(localIndex)]
+			if _, ok := (*slot).(*cell); !ok {
+				*slot = &cell{value: *slot}
+			}
+
+			if err := vm.push(*slot); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.push(frame.cl.Free[freeIndex]); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpCurrentClosure:
+			if err := vm.push(frame.cl); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpUndefined:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			name := unit.Constants[nameIndex].(*object.String).Value
+			return vm.fail(ip, eval.NewError(object.NameError, "identifier not found: "+name))
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			str := eval.Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+
+			if err := vm.push(str); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return vm.fail(ip, err)
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			result := eval.IndexOperator(left, index)
+			if err, ok := result.(*object.Error); ok {
+				return vm.fail(ip, err)
+			}
+			vm.push(result)
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+
+			if err := vm.executeCall(ip, int(numArgs)); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpCallSpread:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+
+			args := make([]object.Object, 0, numArgs)
+			for _, arg := range vm.stack[vm.sp-numArgs : vm.sp] {
+				if s, ok := arg.(*spread); ok {
+					args = append(args, s.elements...)
+				} else {
+					args = append(args, arg)
+				}
+			}
+
+			vm.sp -= numArgs
+			for _, arg := range args {
+				if err := vm.push(arg); err != nil {
+					return vm.fail(ip, err)
+				}
+			}
+
+			if err := vm.executeCall(ip, len(args)); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpSpread:
+			elements, err := eval.Spread(vm.pop())
+			if err != nil {
+				return vm.fail(ip, err)
+			}
+			vm.push(&spread{elements: elements})
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				return returnValue
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			vm.push(returnValue)
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			vm.push(eval.NULL)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			frame.ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpQuote:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numUnquoted := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+
+			template := unit.Constants[constIndex].(*object.Quote).Node
+			values := make([]object.Object, numUnquoted)
+			copy(values, vm.stack[vm.sp-numUnquoted:vm.sp])
+			vm.sp = vm.sp - numUnquoted
+
+			quote := &object.Quote{Node: spliceUnquoted(template, values)}
+			if err := vm.push(quote); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpDupPair:
+			if err := vm.push(vm.stack[vm.sp-2]); err != nil {
+				return vm.fail(ip, err)
+			}
+			if err := vm.push(vm.stack[vm.sp-2]); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := eval.SetIndexOperator(left, index, value); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpIter:
+			items := eval.Iterate(vm.pop())
+			if err, ok := items.(*object.Error); ok {
+				return vm.fail(ip, err)
+			}
+			vm.push(&iterator{elements: items.(*object.Array).Elements})
+
+		case code.OpImport:
+			pathIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			path := unit.Constants[pathIndex].(*object.String).Value
+			module := eval.Import(vm.importer, path, frame.position(ip))
+			if err, ok := module.(*object.Error); ok {
+				return vm.fail(ip, err)
+			}
+			vm.push(module)
+
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			vm.handlers = append(vm.handlers, handler{frames: vm.framesIndex, sp: vm.sp, ip: pos})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			return vm.fail(ip, eval.Throw(vm.pop()))
+
+		case code.OpMatch:
+			patternIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			pattern := unit.Constants[patternIndex].(*object.Quote).Node.(ast.Pattern)
+			values, ok := eval.Match(pattern, vm.pop())
+			for _, value := range values {
+				if err := vm.push(value); err != nil {
+					return vm.fail(ip, err)
+				}
+			}
+
+			result := eval.FALSE
+			if ok {
+				result = eval.TRUE
+			}
+			if err := vm.push(result); err != nil {
+				return vm.fail(ip, err)
+			}
+
+		case code.OpNoMatch:
+			return vm.fail(ip, eval.NoMatch(vm.pop()))
+
+		case code.OpDestructure:
+			patternIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+
+			pattern := unit.Constants[patternIndex].(*object.Quote).Node.(ast.Pattern)
+			values, err := eval.Destructure(pattern, vm.pop())
+			if err != nil {
+				return vm.fail(ip, err)
+			}
+			for _, value := range values {
+				if err := vm.push(value); err != nil {
+					return vm.fail(ip, err)
+				}
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+
+			iter := vm.pop().(*iterator)
+			if iter.next == len(iter.elements) {
+				frame.ip = pos - 1
+				continue
+			}
+
+			vm.push(iter.elements[iter.next])
+			iter.next++
+
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return vm.fail(ip, eval.NewError(object.GenericError, "%s", err))
+			}
+			return vm.fail(ip, eval.NewError(object.GenericError, "unhandled opcode %s", def.Name))
+		}
+	}
+}
+
+// fail attaches the position of the instruction at ip and the active
+// Monkey calls to err, the same information the evaluator collects.
+func (vm *VM) fail(ip int, err *object.Error) object.Object {
+	if !err.Span.IsValid() {
+		err.Span = vm.currentFrame().position(ip)
+	}
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		callee := vm.frames[i]
+		caller := vm.frames[i-1]
+		err.Stack = append(err.Stack, object.Frame{
+			Function: callee.cl.Fn.Name,
+			Call:     caller.position(callee.callSite),
+		})
+	}
+
+	return err
+}
+
+func identifierNotFound(names []string, index int) *object.Error {
+	name := ""
+	if index < len(names) {
+		name = names[index]
+	}
+	return eval.NewError(object.NameError, "identifier not found: "+name)
+}
+
+func (vm *VM) executeCall(ip int, numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(ip, callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return eval.NewError(object.TypeError, "not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(ip int, cl *object.Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+	max := fn.NumParameters
+	if fn.Variadic {
+		max = -1
+	}
+	if err := eval.CheckArity(fn.NumRequired, max, numArgs); err != nil {
+		// The evaluator reports the error from within the call, so the
+		// callee heads the stack here as well.
+		call := vm.currentFrame().position(ip)
+		err.Stack = append(err.Stack, object.Frame{Function: fn.Name, Call: call})
+		return err
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	frame.callSite = ip
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	sp := frame.basePointer + cl.Fn.NumLocals
+	if !vm.growStack(sp + 1) {
+		vm.popFrame()
+		return stackOverflow()
+	}
+
+	// Parameters without an argument are null until their defaults are
+	// bound, and a variadic function finds the arguments after its
+	// parameters in an array in the next slot. The slots of the other
+	// locals start out undefined.
+	params := frame.basePointer + fn.NumParameters
+	locals := params
+	if fn.Variadic {
+		rest := []object.Object{}
+		if numArgs > fn.NumParameters {
+			rest = make([]object.Object, numArgs-fn.NumParameters)
+			copy(rest, vm.stack[params:frame.basePointer+numArgs])
+		}
+		vm.stack[params] = &object.Array{Elements: rest}
+		locals++
+	}
+
+	for i := frame.basePointer + numArgs; i < params; i++ {
+		vm.stack[i] = eval.NULL
+	}
+	for i := locals; i < sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = sp
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+
+	if result == nil {
+		result = eval.NULL
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) *object.Error {
+	unit := vm.currentFrame().cl.Unit
+	constant := unit.Constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return eval.NewError(object.TypeError, "not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free, Unit: unit}
+	return vm.push(closure)
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		pair := object.HashPair{Key: key, Value: value}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, eval.NewError(object.TypeError, "unusable as hash key: %s", key.Type())
+		}
+
+		hashedPairs[hashKey.HashKey()] = pair
+	}
+
+	return &object.Hash{Pairs: hashedPairs}, nil
+}
+
+// spliceUnquoted replaces the numbered unquote placeholders the compiler
+// left in template with the values computed for them.
+func spliceUnquoted(template ast.Node, values []object.Object) ast.Node {
+	if len(values) == 0 {
+		return template
+	}
+
+	return ast.Modify(template, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || call.Function.TokenLiteral() != "unquote" || len(call.Arguments) != 1 {
+			return node
+		}
+
+		placeholder, ok := call.Arguments[0].(*ast.IntegerLiteral)
+		if !ok || placeholder.Value >= int64(len(values)) {
+			return node
+		}
+
+		return eval.ObjectToNode(values[placeholder.Value], call)
+	})
+}
+
+// cell holds a variable that closures have captured. Locals are turned
+// into cells when the first closure captures them, so the closures and the
+// function that created them share the variable rather than copies of its
+// value.
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return "CELL" }
+func (c *cell) Inspect() string         { return "cell" }
+
+// deref returns the value of obj if it is a cell and obj itself otherwise.
+func deref(obj object.Object) object.Object {
+	if c, ok := obj.(*cell); ok {
+		return c.value
+	}
+	return obj
+}
+
+// iterator is the state of a for loop, kept in a slot the compiler reserves
+// for it.
+type iterator struct {
+	elements []object.Object
+	next     int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// spread holds the elements of an array passed with ... until the call
+// they are arguments of runs.
+type spread struct {
+	elements []object.Object
+}
+
+func (s *spread) Type() object.ObjectType { return "SPREAD" }
+func (s *spread) Inspect() string         { return "spread" }
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= len(vm.stack) && !vm.growStack(vm.sp+1) {
+		return stackOverflow()
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
@@ -0,0 +1,427 @@
+package vm
+
+import (
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/eval"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func runVM(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	program := parse(input)
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded := eval.ExpandMacros(program, macroEnv)
+
+	comp := compiler.New()
+	if err := comp.Compile(expanded); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	return vm.Run()
+}
+
+func runEval(input string) object.Object {
+	program := parse(input)
+	macroEnv := object.NewEnvironment()
+	eval.DefineMacros(program, macroEnv)
+	expanded := eval.ExpandMacros(program, macroEnv)
+
+	return eval.Eval(expanded, object.NewEnvironment())
+}
+
+func describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "<nil>"
+	case *object.Error:
+		return "ERROR " + string(obj.Kind) + ": " + obj.Message
+	case *object.Quote:
+		return "QUOTE(" + obj.Node.String() + ")"
+	default:
+		return string(obj.Type()) + " " + obj.Inspect()
+	}
+}
+
+// The vm must agree with the evaluator on every program, errors included.
+func TestParityWithEvaluator(t *testing.T) {
+	tests := []string{
+		"5",
+		"-10",
+		"5 + 5 + 5 + 5 - 10",
+		"(5 + 10 * 2 + 15 / 3) * 2 + -10",
+		"9223372036854775807 + 1",
+		"99999999999999999999 / 3 - 33333333333333333330",
+		"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(30)",
+		"quote(unquote(99999999999999999999 * 10))",
+		"3.14",
+		"-2.5e3",
+		"1 + 0.5",
+		"7 / 2.0",
+		"1.5 < 2",
+		"1 == 1.0",
+		"1.5 + true",
+		`{0.5: "half"}[0.5]`,
+		"quote(unquote(1.5 * 2))",
+		"1 < 2",
+		"1 > 2",
+		"1 != 2",
+		"(1 < 2) == true",
+		"true != false",
+		"!5",
+		"!!true",
+		"if (true) { 10 }",
+		"if (false) { 10 }",
+		"if (1 > 2) { 10 } else { 20 }",
+		"let x = 0; let r = if (true) { x = 2 }; [r, x]",
+		"let r = if (false) { 1 } else { let y = 2; }; [r, 1]",
+		"return 10; 9;",
+		"9; return 2 * 5; 9;",
+		"if (10 > 1) { if (10 > 1) { return 10; } return 1; }",
+		"let a = 5; let b = a; let c = a + b + 5; c;",
+		"let a = 5;",
+		"let a = 1; let a = a + 1; a",
+		"",
+		"let identity = fn(x) { x; }; identity(5);",
+		"let identity = fn(x) { return x; }; identity(5);",
+		"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+		"fn(x) { x; }(5)",
+		"fn(x) { x * 2 }",
+		"let f = fn(a) { a }; f(1, 2, 3)",
+		"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(2);",
+		"let f = fn() { g() }; let g = fn() { 7 }; f()",
+		"let rec = fn() { let a = fn() { x }; let x = 1; a() }; rec()",
+		`let parity = fn(n) {
+		  let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		  let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		  [even(n), odd(n)]
+		}; parity(7)`,
+		"let f = fn() { let g = fn() { y }; if (true) { let y = 2; } g() }; f()",
+		"let f = fn() { let g = fn() { y }; while (true) { let y = 3; break; } g() }; f()",
+		"let f = fn() { let g = fn() { y }; let r = g(); let y = 1; r }; f()",
+		"let f = fn() { y; let y = 1; }; f()",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)",
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9000)",
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9999)",
+		"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(10000)",
+		"quote()",
+		"try { quote(1, 2) } catch (e) { [e[\"kind\"], e[\"message\"]] }",
+		`let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1); }; countDown(50);`,
+		`let wrapper = fn() { let inner = fn(x) { if (x == 0) { 0 } else { inner(x - 1) } }; inner(3) }; wrapper()`,
+		`let a = fn(x) { fn(y) { fn(z) { x + y + z } } }; a(1)(2)(3)`,
+		`"Hello" + " " + "World!"`,
+		`len("four")`,
+		`let n = 3; "n is ${n}, doubled ${n * 2}"`,
+		`"bad ${-true}"`,
+		`quote("x ${unquote(1 + 2)}")`,
+		`len("héllo")`,
+		`"héllo"[1]`,
+		`"abc"[3]`,
+		`len(1)`,
+		`len("one", "two")`,
+		`first([1, 2, 3])`,
+		`rest([1, 2, 3])`,
+		`push([], 1)`,
+		"[1, 2 * 2, 3 + 3]",
+		"let a = [1]; a[0] = a; a",
+		`let h = {"k": 1}; h["k"] = [h]; "${h}"`,
+		`let a = [1]; a[0] = a; try { throw a } catch (e) { e["message"] }`,
+		"[1, 2, 3][1 + 1]",
+		"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+		"[1, 2, 3][3]",
+		"[1, 2, 3][-1]",
+		`{"one": 10 - 9, "two": 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5}[true]`,
+		`{"foo": 5}["bar"]`,
+		`let key = "foo"; {"foo": 5}[key]`,
+		"5 + true;",
+		"5 + true; 5;",
+		"-true",
+		"5; true + false; 5",
+		"foobar",
+		"let f = fn() { x }; f()",
+		"5()",
+		"let f = fn(a, b) { a }; f(1)",
+		`"Hello" - "World"`,
+		`{"name": "Monkey"}[fn(x) { x }];`,
+		`{fn(x) { x }: 1}`,
+		"quote(foobar + barfoo)",
+		"quote(8 + unquote(4 + 4))",
+		"let quotedInfixExpression = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfixExpression))",
+		"let i = 0; while (i < 5) { let i = i + 1; }; i",
+		"let i = 0; while (true) { let i = i + 1; if (i == 3) { break; } }; i",
+		"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } s = s + x; }; s",
+		`let s = ""; for (c in "héllo") { s = c + s; }; s`,
+		`let ks = [0]; for (k in {"b": 1, 2: 0, "a": 1, 1.5: 0, true: 0, -1: 0}) { ks = push(ks, k); }; ks`,
+		"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } n = n + 1; } }; n",
+		"let x = 10; for (x in [1, 2]) { x += 1 }; x",
+		"let f = fn() { let x = 10; let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }) }; [x, fs[0]()] }; f()",
+		"for (x in [1]) { let y = 2; }; y",
+		"let f = fn(xs) { let n = 0; for (x in xs) { if (x > 2) { return n; } n = n + x; } -1 }; [f([1, 2, 3]), f([1])]",
+		"let f = fn() { let i = 0; while (i < 3) { let i = i + 1; } i }; f()",
+		"let f = fn(xs) { let n = 0; for (x in xs) { for (y in xs) { if (y > x) { continue; } n = n + 10 * x + y; } } n }; f([1, 2, 3])",
+		"for (x in 5) { x }",
+		"for (x in [1, 2]) { x + true }",
+		"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x",
+		"let i = 0; let f = fn() { i = i + 1; }; f(); f(); i",
+		"let x = 1; let f = fn() { let x = 5; x = 6; x }; [f(), x]",
+		"let counter = fn() { let n = 0; [fn() { n += 1; n }, fn() { n }] }; let c = counter(); c[0](); c[0](); c[1]()",
+		"let f = fn() { let n = 0; let inc = fn() { n += 1 }; inc(); inc(); n }; f()",
+		"let f = fn() { let n = 0; let g = fn() { fn() { n += 1; n } }; let h = g(); h(); n += 10; h() }; f()",
+		"let f = fn() { let fs = [0]; for (i in [1, 2]) { fs = push(fs, fn() { i }); } [fs[1](), fs[2]()] }; f()",
+		"let f = fn(a) { let g = fn() { a *= 2 }; g(); a }; f(21)",
+		"let i = 0; let s = 0; while (i < 4) { i += 1; s += i; }; s",
+		"while (false) {}",
+		"let s = 0; for (x in [1, 2]) { s += x }",
+		"let f = fn() { while (false) {} }; f()",
+		"let x = 1",
+		"let a = [1, 2, 3]; a[1] = 20; a[2] *= 10; a",
+		"let a = [1, 2]; let b = a; b[0] = 5; a",
+		`let h = {"a": 1}; h["b"] = 2; h["a"] += 10; [h["a"], h["b"]]`,
+		"let f = fn() { let y = 1; y = 2; }; f()",
+		"x = 1",
+		"len = 1",
+		"x = 1; let x = 2; x",
+		"let f = fn() { if (false) { let y = 1; } y = 2; }; f()",
+		"let a = [1]; a[1] = 2",
+		`let s = "abc"; s[0] = "x"`,
+		"true && missing",
+		"false && missing",
+		"true || missing",
+		"false || 0",
+		"null || false",
+		"1 && null",
+		"let n = 0; let bump = fn() { n += 1; true }; false && bump(); true || bump(); true && bump(); n",
+		"let f = fn(x) { x > 1 && x < 10 || x == 100 }; [f(5), f(50), f(100)]",
+		"[7 % 3, -7 % 3, 7.5 % 2, 2 ** 10, 2 ** 64, 3 <= 3, 3 >= 4, 12 & 10, 12 | 10, 12 ^ 10, 1 << 64, -16 >> 2]",
+		"1 / 0",
+		"1 % 0",
+		"2 ** -1",
+		"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }",
+		"if (1 > 2) { 10 } else if (2 > 3) { 20 }",
+		"let f = fn(n) { if (n < 0) { -1 } else if (n == 0) { 0 } else { 1 } }; [f(-5), f(0), f(5)]",
+		"null",
+		"[null, null == null, [1][5] == null, \"ab\"[5] == null, {}[1] == null]",
+		"quote(unquote(null))",
+		`import "lib/strings"; 1`,
+		`try { throw "boom" } catch (e) { [e["message"], e["kind"], e["position"], e["value"]] }`,
+		`try { len(1, 2) } catch (e) { [e["kind"], e["message"]] }`,
+		`try { 1 } catch (e) { 2 }`,
+		`try { let x = 1; } catch (e) { 2 }`,
+		`let log = [0]; let r = try { 1 / 0 } catch (e) { log = push(log, e["kind"]); 2 } finally { log = push(log, "f") }; [r, log]`,
+		`try { throw "a" } finally { 1 }`,
+		`try { throw "a" } catch (e) { throw e["message"] + "b" }`,
+		`try { throw "a" } catch (e) { 1 } finally { throw "c" }`,
+		`try { throw [1] } catch (e) { e }`,
+		`try { throw "a" } catch (e) { e["stack"] }`,
+		`let e = 9; try { throw "a" } catch (e) { 1 }; e`,
+		`let e = 9; try { throw "a" } catch (e) { let y = e; 1 }; [e, try { y } catch (e) { e["message"] }]`,
+		`let f = fn() { let e = 9; try { throw "a" } catch (e) { return 1 } finally { throw e } }; f()`,
+		`let log = [0]; for (x in [1]) { try { throw "a" } catch (x) { break } finally { log = push(log, x) } } log`,
+		`match (1) { e => try { throw "a" } catch (c) { e } finally { e } }`,
+		`let f = fn() { try { return 1 } finally { return 2 } }; f()`,
+		`let n = 0; let f = fn() { try { return n } finally { n += 1 } }; [f(), f(), n]`,
+		`let f = fn() { try { throw "x" } catch (e) { return e["message"] } finally { 1 }; 3 }; f()`,
+		`let f = fn(g) { try { g() } catch (e) { e["message"] } }; [f(fn() { 1 }), f(fn() { throw "inner" }), f(fn() { missing })]`,
+		`let inner = fn() { 1 / 0 }; let mid = fn() { try { inner() } catch (e) { throw e } }; let outer = fn() { 1 + mid() }; try { outer() } catch (e) { e["position"] }; outer()`,
+		`let xs = [0]; for (i in [1, 2, 3, 4]) { try { if (i == 2) { continue } if (i == 4) { break } xs = push(xs, i) } finally { xs = push(xs, -i) } }; xs`,
+		`let i = 0; while (true) { try { try { break } finally { i += 1 } } finally { i += 10 } }; i`,
+		`let f = fn() { for (x in [1, 2]) { try { return x } finally { break } } 9 }; f()`,
+		`let f = fn() { 1 + try { throw "x" } catch (e) { 2 } }; f()`,
+		`match (1) { 0 => "zero", 1 => "one", _ => "many" }`,
+		`match (-2) { -2 => "minus two", _ => "other" }`,
+		`[match (1.0) { 1 => "int", 1.0 => "float" }, match (null) { false => 1, null => 2 }, match ("b") { "a" => 1, "b" => 2 }]`,
+		`match ([1, 2, 3]) { [head, ...tail] => [head, tail] }`,
+		`match ([1, [2, 3]]) { [a, [b, c]] => a + b + c }`,
+		`match ({"name": "Ada", "age": 36}) { {"name": n, age} => [n, age] }`,
+		`match ({"a": 1}) { {"b": b} => b, {} => "any hash" }`,
+		`let f = fn(n) { match (n) { n if n < 0 => "negative", n if n > 0 => "positive", _ => "zero" } }; [f(-1), f(1), f(0)]`,
+		`match ([3, 1]) { [a, b] if a < b => "sorted", [a, b] => [b, a] }`,
+		`let x = 1; match (2) { x => x }; x`,
+		`let x = 1; match (5) { x if false => 0, _ => 1 }; x`,
+		`let x = 1; let f = fn() { x }; match ([7]) { [x] => 0 }; [f(), x]`,
+		`let f = fn() { let x = 1; let g = fn() { x }; let r = match ([7]) { [x] => [x, g()] }; [r, x, g()] }; f()`,
+		`let f = fn(v) { match (v) { [x] => fn() { x } } }; let a = f([1]); let b = f([2]); [a(), b()]`,
+		`let x = 1; let set = fn(v) { x = v }; match (5) { y => set(y) }; x`,
+		`let f = fn(xs) { match (xs) { [] => 0, [h, ...t] => h + f(t) } }; f([1, 2, 3, 4])`,
+		`let f = fn(v) { match (match (v) { 1 => [2], _ => [] }) { [n] if match (n) { 2 => true } => n, _ => 0 } }; [f(1), f(5)]`,
+		`let g = fn() { let k = 10; fn(v) { match (v) { [a] => a + k, _ => k } } }; let h = g(); [h([1]), h(2)]`,
+		`match (5) { 1 => 2 }`,
+		`match (1) { n if n + true => 1 }`,
+		`try { match ("x") { } } catch (e) { e["kind"] }`,
+		`let firstPositive = macro(xs, fallback) { quote(match (unquote(xs)) { [x, ..._] if x > 0 => x, _ => unquote(fallback) }) }; [firstPositive([1, 2], 0), firstPositive([-1], 0)]`,
+		`let [a, b, ...rest] = [1, 2, 3, 4]; [a, b, rest]`,
+		`let {name, "age": age = 30, tags = []} = {"name": "Bo", "tags": null}; [name, age, tags]`,
+		`let [x, [y, z = x + y]] = [1, [2]]; [x, y, z]`,
+		`let f = fn() { let [a, b = a * 2, c = b + 1] = [1]; [a, b, c] }; f()`,
+		`let f = fn([a, b], {k}, c) { a + b + k + c }; f([1, 2], {"k": 3}, 4)`,
+		`let f = fn(x, [h, t = x]) { [h, t] }; [f(0, [1]), f(0, [1, 2])]`,
+		`let g = fn([a, b]) { fn() { a + b } }; g([1, 2])()`,
+		`match ([1]) { [a, b = a + 1] => [a, b] }`,
+		`let [a, b] = [1, 2, 3]`,
+		`let {"p": {q}} = {"p": [1]}`,
+		`let [a, b = missing] = [1]`,
+		`let f = fn(x, [a]) { a }; f(1, {})`,
+		`try { let {x} = {} } catch (e) { [e["kind"], e["message"]] }`,
+		`let f = fn(x, y = x * 2, z = x + y) { [x, y, z] }; [f(1), f(1, 5), f(1, null, 0)]`,
+		`let f = fn(first, ...rest) { [first, rest] }; [f(1), f(1, 2, 3)]`,
+		`let f = fn([a, b], n = a + b, ...more) { [n, more] }; f([1, 2], null, 3)`,
+		`let f = fn(n = 2) { fn(...xs) { len(xs) * n } }; [f()(1, 2), f(3)(...[1])]`,
+		`let f = fn(...xs) { xs }; [f(), f(0, ...[1, 2], 3, ...[]), len(...["abc"])]`,
+		`let f = fn(x) { x }; f(1, 2)`,
+		`let f = fn(x, y = 1) { x }; f()`,
+		`let f = fn(x, ...r) { x }; f()`,
+		`let f = fn(x) { x }; f(...1)`,
+		`let f = fn(x = missing) { x }; f()`,
+		`let f = fn(x) { x }; f`,
+		`let second = macro(first, ...rest) { rest[0] }; second(1, 2 + 3, 4)`,
+		`let m = macro(x) { x }; m(1, 2)`,
+		`let m = macro(...xs) { quote(len(unquote(xs))) }; m(1, 2, 3)`,
+		`let inc = macro(x, by = 1, ...more) { quote(unquote(x) + unquote(by) + len(unquote(more))) }; [inc(1), inc(1, 10), inc(1, 10, 100, 1000)]`,
+		`let m = macro(x, y = missing) { x }; m(1)`,
+		`let m = macro() { quote(unquote(fn() { 1 })) }; try { m() } catch (e) { [e["kind"], e["message"]] }`,
+		`quote(unquote([1, [2 + 3], null]))`,
+		`let m = macro(x) { x }; try { m() } catch (e) { [e["kind"], e["message"]] }`,
+		`let m = macro() { throw "x" }; try { m() } catch (e) { [e["kind"], e["message"]] }`,
+		`let m = macro() { 1 }; m()`,
+		`let m = macro() { let x = 1; }; m()`,
+		`let m = macro() { return quote(2); 3 }; m()`,
+		"let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons); } else { unquote(alt); }); }; unless(10 > 5, 1, 2);",
+	}
+
+	for _, input := range tests {
+		want := describe(runEval(input))
+		got := describe(runVM(t, input))
+
+		if got != want {
+			t.Errorf("vm and evaluator disagree on %q.\neval=%s\nvm  =%s", input, want, got)
+		}
+	}
+}
+
+func TestErrorPositionsAndStack(t *testing.T) {
+	inputs := []string{
+		`let inner = fn(a) {
+  a + y
+};
+let outer = fn(b) {
+  inner(b * 2)
+};
+let result = outer(1);`,
+		`let add = fn(a, b) { a + b };
+let outer = fn() {
+  add(1)
+};
+outer();`,
+	}
+
+	for _, input := range inputs {
+		evaluated := runEval(input)
+		result := runVM(t, input)
+
+		want, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Fatalf("evaluator returned no error. got=%T(%+v)", evaluated, evaluated)
+		}
+		got, ok := result.(*object.Error)
+		if !ok {
+			t.Fatalf("vm returned no error. got=%T(%+v)", result, result)
+		}
+
+		if got.Span.Start != want.Span.Start {
+			t.Errorf("wrong error position. want=%s, got=%s", want.Span.Start, got.Span.Start)
+		}
+
+		if got.Traceback() != want.Traceback() {
+			t.Errorf("wrong traceback.\nwant=%s\ngot =%s", want.Traceback(), got.Traceback())
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	inputs := []string{
+		"let f = fn(n) { f(n + 1) + 1 }; f(0)",
+		"let f = fn(n) { f(n + 1) + 1 }; try { f(0) } catch (e) { 1 } finally { 2 }",
+	}
+
+	for _, input := range inputs {
+		result := runVM(t, input)
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned for %q. got=%T(%+v)", input, result, result)
+		}
+
+		if errObj.Message != "call depth limit of 10000 exceeded" || errObj.Limit != object.CallDepthLimit {
+			t.Errorf("wrong error for %q. got=%q (limit %q)", input, errObj.Message, errObj.Limit)
+		}
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	symbolTable := compiler.New().SymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, GlobalsSize)
+
+	lines := []string{"let a = 2;", "let double = fn(x) { x * a };", "double(21)"}
+
+	var result object.Object
+	for _, line := range lines {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(line)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		result = NewWithGlobalsStore(bytecode, globals).Run()
+	}
+
+	if describe(result) != "INTEGER 42" {
+		t.Errorf("wrong result. got=%s", describe(result))
+	}
+}
+
+const fibonacci = `
+let fibonacci = fn(x) {
+  if (x < 2) {
+    x
+  } else {
+    fibonacci(x - 1) + fibonacci(x - 2)
+  }
+};
+fibonacci(20);
+`
+
+func BenchmarkFibonacciEval(b *testing.B) {
+	program := parse(fibonacci)
+
+	for i := 0; i < b.N; i++ {
+		eval.Eval(program, object.NewEnvironment())
+	}
+}
+
+func BenchmarkFibonacciVM(b *testing.B) {
+	program := parse(fibonacci)
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		b.Fatalf("compiler error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New(bytecode).Run()
+	}
+}